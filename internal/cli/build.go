@@ -6,9 +6,12 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"time"
 
 	"github.com/JHashimoto0518/ttlx/internal/config"
 	"github.com/JHashimoto0518/ttlx/internal/generator"
+	"github.com/JHashimoto0518/ttlx/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -26,6 +29,18 @@ var buildCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("failed to get dry-run flag: %w", err)
 		}
+		reproducible, err := cmd.Flags().GetBool("reproducible")
+		if err != nil {
+			return fmt.Errorf("failed to get reproducible flag: %w", err)
+		}
+		sourceHash, err := cmd.Flags().GetBool("source-hash")
+		if err != nil {
+			return fmt.Errorf("failed to get source-hash flag: %w", err)
+		}
+		format, err := outputFlags(cmd)
+		if err != nil {
+			return err
+		}
 
 		// 1. 設定読み込み
 		cfg, err := config.LoadConfig(configPath)
@@ -33,16 +48,28 @@ var buildCmd = &cobra.Command{
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		// 2. バリデーション
+		// 2. バリデーション（--route / --tag で選択したルートのみ。フラグの出力形式は options.output より優先）
+		if err := selectRoutes(cmd, cfg); err != nil {
+			return err
+		}
+		output.Override(cfg, format)
 		if err := config.Validate(cfg); err != nil {
 			return fmt.Errorf("validation failed: %w", err)
 		}
 
 		// 3. TTL生成
-		ttls, err := generator.GenerateAll(cfg, filepath.Base(configPath))
+		opts, err := headerOptions(configPath, reproducible, sourceHash)
+		if err != nil {
+			return err
+		}
+		ttls, err := generator.GenerateAllWithOptions(cfg, filepath.Base(configPath), opts)
 		if err != nil {
 			return fmt.Errorf("failed to generate TTL: %w", err)
 		}
+		encoded, err := encodeAll(ttls, cfg.Options.Output)
+		if err != nil {
+			return err
+		}
 
 		// 4. 出力
 		if dryRun {
@@ -74,9 +101,9 @@ var buildCmd = &cobra.Command{
 
 		// 各ルートのTTLファイルを書き込み
 		var generatedFiles []string
-		for routeName, ttl := range ttls {
+		for routeName, data := range encoded {
 			filename := filepath.Join(outputDir, routeName+".ttl")
-			if err := os.WriteFile(filename, []byte(ttl), 0644); err != nil {
+			if err := os.WriteFile(filename, data, 0644); err != nil {
 				return fmt.Errorf("failed to write TTL file '%s': %w", filename, err)
 			}
 			generatedFiles = append(generatedFiles, filename)
@@ -93,7 +120,96 @@ var buildCmd = &cobra.Command{
 	},
 }
 
+// headerOptions returns the header settings of the generated scripts.
+// SOURCE_DATE_EPOCH pins the timestamp; otherwise --reproducible and --source-hash
+// omit it so that the output only changes when the configuration does.
+func headerOptions(configPath string, reproducible, sourceHash bool) (generator.Options, error) {
+	var opts generator.Options
+
+	if sourceHash {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return opts, fmt.Errorf("failed to read config: %w", err)
+		}
+		opts.SourceHash = generator.SourceHash(data)
+	}
+
+	// https://reproducible-builds.org/specs/source-date-epoch/
+	if epoch, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok && epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil || seconds < 0 {
+			return opts, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %s", epoch)
+		}
+		opts.Timestamp = time.Unix(seconds, 0).UTC()
+		return opts, nil
+	}
+
+	if !reproducible && !sourceHash {
+		opts.Timestamp = time.Now()
+	}
+	return opts, nil
+}
+
+// outputFlags returns the --encoding and --eol flags; empty values keep options.output.
+func outputFlags(cmd *cobra.Command) (config.Output, error) {
+	var format config.Output
+	var err error
+	if format.Encoding, err = cmd.Flags().GetString("encoding"); err != nil {
+		return format, fmt.Errorf("failed to get encoding flag: %w", err)
+	}
+	if format.EOL, err = cmd.Flags().GetString("eol"); err != nil {
+		return format, fmt.Errorf("failed to get eol flag: %w", err)
+	}
+	return format, nil
+}
+
+// selectRoutes keeps only the routes selected by the --route and --tag flags.
+func selectRoutes(cmd *cobra.Command, cfg *config.Config) error {
+	patterns, err := cmd.Flags().GetStringArray("route")
+	if err != nil {
+		return fmt.Errorf("failed to get route flag: %w", err)
+	}
+	tags, err := cmd.Flags().GetStringArray("tag")
+	if err != nil {
+		return fmt.Errorf("failed to get tag flag: %w", err)
+	}
+	if len(patterns) == 0 && len(tags) == 0 {
+		return nil
+	}
+
+	names, err := config.SelectRoutes(cfg, patterns, tags)
+	if err != nil {
+		return err
+	}
+	config.KeepRoutes(cfg, names)
+	return nil
+}
+
+// addRouteFlags adds the --route and --tag flags used by selectRoutes.
+func addRouteFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("route", nil, "Only include routes matching this name or glob pattern (repeatable)")
+	cmd.Flags().StringArray("tag", nil, "Only include routes with this tag (repeatable)")
+}
+
+// encodeAll encodes the script of each route, naming the route when a character cannot be encoded.
+func encodeAll(ttls map[string]string, format *config.Output) (map[string][]byte, error) {
+	encoded := make(map[string][]byte, len(ttls))
+	for routeName, ttl := range ttls {
+		data, err := output.Encode(ttl, format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode route '%s': %w", routeName, err)
+		}
+		encoded[routeName] = data
+	}
+	return encoded, nil
+}
+
 func init() {
 	buildCmd.Flags().StringP("output", "o", "", "Output directory path")
 	buildCmd.Flags().Bool("dry-run", false, "Print to stdout instead of file")
+	buildCmd.Flags().Bool("reproducible", false, "Omit the generation timestamp (SOURCE_DATE_EPOCH pins it instead)")
+	buildCmd.Flags().Bool("source-hash", false, "Embed the SHA-256 of the config file instead of the generation timestamp")
+	buildCmd.Flags().String("encoding", "", "Output encoding: utf8, utf8-bom, or sjis (default: options.output.encoding or utf8)")
+	buildCmd.Flags().String("eol", "", "Output line endings: lf or crlf (default: options.output.eol or lf)")
+	addRouteFlags(buildCmd)
 }
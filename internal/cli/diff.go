@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/JHashimoto0518/ttlx/internal/differ"
+	"github.com/JHashimoto0518/ttlx/internal/generator"
+	"github.com/JHashimoto0518/ttlx/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// generatedMarker identifies TTL files written by ttlx in the output directory.
+const generatedMarker = "; Generated by ttlx"
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <config.yml>",
+	Short: "Show how the configuration changes the generated TTL scripts",
+	Long: `diff regenerates the TTL scripts in memory and compares them with the
+scripts in the output directory, the scripts generated from another config
+file (--against), or the scripts generated from the config file at a git
+revision (--rev). Header timestamps and source hashes are ignored.
+The exit status is 1 when any route differs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := args[0]
+		outputPath, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return fmt.Errorf("failed to get output flag: %w", err)
+		}
+		against, err := cmd.Flags().GetString("against")
+		if err != nil {
+			return fmt.Errorf("failed to get against flag: %w", err)
+		}
+		rev, err := cmd.Flags().GetString("rev")
+		if err != nil {
+			return fmt.Errorf("failed to get rev flag: %w", err)
+		}
+
+		// 1. 現在の設定からTTL生成
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		sourceFile := filepath.Base(configPath)
+		current, err := generateForDiff(cfg, sourceFile)
+		if err != nil {
+			return err
+		}
+
+		// 2. 比較対象の取得
+		var previous map[string]string
+		var previousLabel string
+		switch {
+		case against != "":
+			againstCfg, err := config.LoadConfig(against)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			// Source 行の差分が出ないよう同じファイル名で生成
+			previous, err = generateForDiff(againstCfg, sourceFile)
+			if err != nil {
+				return err
+			}
+			previousLabel = against
+		case rev != "":
+			data, err := gitShow(configPath, rev)
+			if err != nil {
+				return err
+			}
+			revCfg, err := config.ParseConfig(data)
+			if err != nil {
+				return fmt.Errorf("failed to load config at %s: %w", rev, err)
+			}
+			previous, err = generateForDiff(revCfg, sourceFile)
+			if err != nil {
+				return err
+			}
+			previousLabel = rev
+		default:
+			outputDir := "."
+			if outputPath != "" {
+				outputDir = outputPath
+			}
+			previous, err = readGeneratedTTLs(outputDir, sourceFile)
+			if err != nil {
+				return err
+			}
+			previousLabel = outputDir
+		}
+
+		// 3. 差分表示
+		results, err := differ.Compare(previous, current, previousLabel, sourceFile)
+		if err != nil {
+			return fmt.Errorf("failed to compare TTL: %w", err)
+		}
+		printDiff(cmd.OutOrStdout(), results)
+
+		// 差分がある場合は終了コード 1（CI での検出用）。差分は表示済みのため、エラーと使い方は表示しない
+		if differ.HasChanges(results) {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return errDifferencesFound
+		}
+		return nil
+	},
+}
+
+// errDifferencesFound makes ttlx diff exit with a non-zero status when the scripts differ.
+var errDifferencesFound = errors.New("differences found")
+
+// generateForDiff validates cfg and generates its scripts without volatile header lines.
+func generateForDiff(cfg *config.Config, sourceFile string) (map[string]string, error) {
+	if err := config.Validate(cfg); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	ttls, err := generator.GenerateAllWithOptions(cfg, sourceFile, generator.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TTL: %w", err)
+	}
+	return ttls, nil
+}
+
+// readGeneratedTTLs reads the scripts in dir generated from the config file sourceFile
+// as UTF-8 with LF line endings, keyed by route name. TTL files written by hand or
+// generated from other config files in a shared output directory are skipped so that
+// they are not reported as removed routes.
+func readGeneratedTTLs(dir, sourceFile string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	ttls := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".ttl" {
+			continue
+		}
+		filename := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TTL file '%s': %w", filename, err)
+		}
+		// 文字コード・改行コードの違いは比較しない
+		ttl, err := output.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TTL file '%s': %w", filename, err)
+		}
+		if !strings.Contains(ttl, generatedMarker) || !differ.FromSource(ttl, sourceFile) {
+			continue
+		}
+		ttls[strings.TrimSuffix(entry.Name(), ".ttl")] = ttl
+	}
+	return ttls, nil
+}
+
+// gitShow reads the config file as it was at the git revision rev.
+func gitShow(configPath, rev string) ([]byte, error) {
+	// 設定ファイルのディレクトリから ./ 相対で指定し、リポジトリ内の位置に依存しない
+	cmd := exec.Command("git", "show", rev+":./"+filepath.Base(configPath))
+	cmd.Dir = filepath.Dir(configPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("failed to read config at %s: %s", rev, msg)
+		}
+		return nil, fmt.Errorf("failed to read config at %s: %w", rev, err)
+	}
+	return out, nil
+}
+
+// printDiff prints the unified diff of each changed route followed by a summary.
+func printDiff(w io.Writer, results []differ.RouteDiff) {
+	counts := make(map[differ.Status]int)
+	for _, r := range results {
+		counts[r.Status]++
+		if r.Status == differ.Unchanged {
+			continue
+		}
+		fmt.Fprintf(w, "=== %s.ttl (%s) ===\n", r.Route, r.Status)
+		fmt.Fprint(w, r.Diff)
+		fmt.Fprintln(w)
+	}
+
+	if !differ.HasChanges(results) {
+		fmt.Fprintln(w, "No differences")
+		return
+	}
+	fmt.Fprintf(w, "Routes: %d modified, %d added, %d removed, %d unchanged\n",
+		counts[differ.Modified], counts[differ.Added], counts[differ.Removed], counts[differ.Unchanged])
+}
+
+func init() {
+	diffCmd.Flags().StringP("output", "o", "", "Directory containing the previously generated TTL files")
+	diffCmd.Flags().String("against", "", "Compare with the TTL generated from another config file")
+	diffCmd.Flags().String("rev", "", "Compare with the TTL generated from the config file at a git revision")
+	diffCmd.MarkFlagsMutuallyExclusive("output", "against", "rev")
+}
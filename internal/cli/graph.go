@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/JHashimoto0518/ttlx/internal/graph"
+	"github.com/spf13/cobra"
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph <config.yml>",
+	Short: "Export the connection topology as Graphviz DOT or Mermaid",
+	Long: `graph renders profiles as nodes and route steps as edges labelled with
+the route names and auth type. The first step of each route is drawn from
+the Tera Term node.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return fmt.Errorf("failed to get format flag: %w", err)
+		}
+		if format != "dot" && format != "mermaid" {
+			return fmt.Errorf("invalid format: %s (must be 'dot' or 'mermaid')", format)
+		}
+
+		// 1. 設定読み込み
+		cfg, err := config.LoadConfig(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		// 2. バリデーション（--route / --tag で選択したルートのみ）
+		if err := selectRoutes(cmd, cfg); err != nil {
+			return err
+		}
+		if err := config.Validate(cfg); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+
+		// 3. グラフ生成
+		g := graph.Build(cfg)
+		if format == "mermaid" {
+			fmt.Fprint(cmd.OutOrStdout(), g.Mermaid())
+		} else {
+			fmt.Fprint(cmd.OutOrStdout(), g.DOT())
+		}
+		return nil
+	},
+}
+
+func init() {
+	graphCmd.Flags().String("format", "dot", "Output format: dot or mermaid")
+	addRouteFlags(graphCmd)
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/JHashimoto0518/ttlx/internal/wizard"
+	"github.com/spf13/cobra"
+)
+
+// defaultConfigFile is the file written by init when no path is given.
+const defaultConfigFile = "ttlx.yml"
+
+var initCmd = &cobra.Command{
+	Use:   "init [config.yml]",
+	Short: "Create a configuration file interactively",
+	Long: `init asks for the hosts, users, and authentication of each hop in
+connection order and writes a commented configuration file that passes
+validation. With --from-flags the answers are taken from flags instead,
+for example:
+
+  ttlx init --from-flags --hop bastion=user1@bastion.example.com --hop user2@10.0.0.50`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := defaultConfigFile
+		if len(args) > 0 {
+			configPath = args[0]
+		}
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			return fmt.Errorf("failed to get force flag: %w", err)
+		}
+		fromFlags, err := cmd.Flags().GetBool("from-flags")
+		if err != nil {
+			return fmt.Errorf("failed to get from-flags flag: %w", err)
+		}
+
+		// 既存ファイルは --force 指定時のみ上書き
+		if _, err := os.Stat(configPath); err == nil && !force {
+			return fmt.Errorf("file already exists: %s (use --force to overwrite)", configPath)
+		}
+
+		// 1. 回答の取得
+		var answers *wizard.Answers
+		if fromFlags {
+			answers, err = answersFromFlags(cmd)
+		} else {
+			answers, err = wizard.Ask(cmd.InOrStdin(), cmd.OutOrStdout())
+		}
+		if err != nil {
+			return err
+		}
+
+		// 2. YAML生成（バリデーション込み）
+		out, err := wizard.Build(answers)
+		if err != nil {
+			return fmt.Errorf("failed to create config: %w", err)
+		}
+
+		// 3. 出力
+		if err := os.WriteFile(configPath, []byte(out), 0644); err != nil {
+			return fmt.Errorf("failed to write config file '%s': %w", configPath, err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "\nCreated %s\n", configPath)
+		fmt.Fprintf(cmd.OutOrStdout(), "Next: ttlx build %s\n", configPath)
+		return nil
+	},
+}
+
+// answersFromFlags builds the wizard answers from the non-interactive flags.
+// The auth settings apply to every hop.
+func answersFromFlags(cmd *cobra.Command) (*wizard.Answers, error) {
+	flags := cmd.Flags()
+	hopSpecs, err := flags.GetStringArray("hop")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hop flag: %w", err)
+	}
+	if len(hopSpecs) == 0 {
+		return nil, errors.New("--from-flags requires at least one --hop")
+	}
+	routeName, err := flags.GetString("route")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route flag: %w", err)
+	}
+	promptMarker, err := flags.GetString("prompt-marker")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt-marker flag: %w", err)
+	}
+	authType, err := flags.GetString("auth")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth flag: %w", err)
+	}
+	passwordFile, err := flags.GetString("password-file")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password-file flag: %w", err)
+	}
+	passwordPrompt, err := flags.GetString("password-prompt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password-prompt flag: %w", err)
+	}
+	keyPath, err := flags.GetString("key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key flag: %w", err)
+	}
+
+	answers := &wizard.Answers{RouteName: routeName}
+	for i, spec := range hopSpecs {
+		hop, err := wizard.ParseHop(spec, i+1)
+		if err != nil {
+			return nil, err
+		}
+		hop.PromptMarker = promptMarker
+		hop.AuthType = authType
+		switch authType {
+		case "password":
+			hop.PasswordFile = passwordFile
+			if i > 0 {
+				hop.PasswordPrompt = passwordPrompt
+			}
+		case "keyfile":
+			hop.KeyPath = keyPath
+			if i > 0 {
+				hop.IdentityFile = keyPath
+			}
+		default:
+			return nil, fmt.Errorf("invalid auth type: %s (must be 'password' or 'keyfile')", authType)
+		}
+		answers.Hops = append(answers.Hops, hop)
+	}
+	return answers, nil
+}
+
+func init() {
+	initCmd.Flags().Bool("force", false, "Overwrite an existing file")
+	initCmd.Flags().Bool("from-flags", false, "Take the answers from flags instead of asking")
+	initCmd.Flags().StringArray("hop", nil, "Hop as [name=]user@host[:port], repeated in connection order (with --from-flags)")
+	initCmd.Flags().String("route", wizard.DefaultRouteName, "Route name (with --from-flags)")
+	initCmd.Flags().String("prompt-marker", wizard.DefaultPromptMarker, "Prompt marker of every hop (with --from-flags)")
+	initCmd.Flags().String("auth", "password", "Auth type of every hop: password or keyfile (with --from-flags)")
+	initCmd.Flags().String("password-file", wizard.DefaultPasswordFile, "Password file for password auth (with --from-flags)")
+	initCmd.Flags().String("password-prompt", wizard.DefaultPasswordPrompt, "Password prompt of later hops (with --from-flags)")
+	initCmd.Flags().String("key", wizard.DefaultKeyPath, "Private key path for keyfile auth (with --from-flags)")
+}
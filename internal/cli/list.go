@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/JHashimoto0518/ttlx/internal/inventory"
+	"github.com/spf13/cobra"
+)
+
+// hopSeparator joins the steps of a hop chain in text output.
+const hopSeparator = " → "
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List routes and profiles of a configuration",
+}
+
+var listRoutesCmd = &cobra.Command{
+	Use:   "routes <config.yml>",
+	Short: "List routes with their hop chains",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, format, err := loadForList(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		routes := inventory.Routes(cfg)
+		if format == "json" {
+			return writeJSON(cmd.OutOrStdout(), routes)
+		}
+
+		tw := newTable(cmd.OutOrStdout(), "ROUTE", "HOPS", "AUTH", "COMMANDS", "OUTPUT")
+		for _, r := range routes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", r.Name,
+				strings.Join(r.Profiles(), hopSeparator), strings.Join(r.AuthTypes(), hopSeparator), r.Commands, r.OutputFile)
+		}
+		return tw.Flush()
+	},
+}
+
+var listProfilesCmd = &cobra.Command{
+	Use:   "profiles <config.yml>",
+	Short: "List profiles with the routes that use them",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, format, err := loadForList(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		profiles := inventory.Profiles(cfg)
+		if format == "json" {
+			return writeJSON(cmd.OutOrStdout(), profiles)
+		}
+
+		tw := newTable(cmd.OutOrStdout(), "PROFILE", "HOST", "PORT", "USER", "AUTH", "DEVICE", "ROUTES")
+		for _, p := range profiles {
+			routes := strings.Join(p.Routes, ", ")
+			if routes == "" {
+				routes = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", p.Name, p.Host, p.Port, p.User, p.Auth, p.DeviceType, routes)
+		}
+		return tw.Flush()
+	},
+}
+
+var listWhereUsedCmd = &cobra.Command{
+	Use:   "where-used <config.yml> <profile>",
+	Short: "List every route step that references a profile",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, format, err := loadForList(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		usages, err := inventory.WhereUsed(cfg, args[1])
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			return writeJSON(cmd.OutOrStdout(), usages)
+		}
+
+		if len(usages) == 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "Profile '%s' is not used by any route\n", args[1])
+			return nil
+		}
+		tw := newTable(cmd.OutOrStdout(), "ROUTE", "STEP")
+		for _, u := range usages {
+			fmt.Fprintf(tw, "%s\t%s\n", u.Route, strconv.Itoa(u.Step)+"/"+strconv.Itoa(u.Hops))
+		}
+		return tw.Flush()
+	},
+}
+
+// loadForList loads and validates the configuration and returns the output format.
+func loadForList(cmd *cobra.Command, configPath string) (*config.Config, string, error) {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get output flag: %w", err)
+	}
+	if format != "text" && format != "json" {
+		return nil, "", fmt.Errorf("invalid output format: %s (must be 'text' or 'json')", format)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := config.Validate(cfg); err != nil {
+		return nil, "", fmt.Errorf("validation failed: %w", err)
+	}
+	return cfg, format, nil
+}
+
+func newTable(w io.Writer, headers ...string) *tabwriter.Writer {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	return tw
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func init() {
+	listCmd.PersistentFlags().String("output", "text", "Output format: text or json")
+	listCmd.AddCommand(listRoutesCmd)
+	listCmd.AddCommand(listProfilesCmd)
+	listCmd.AddCommand(listWhereUsedCmd)
+}
@@ -17,6 +17,11 @@ func Execute() error {
 
 func init() {
 	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(watchCmd)
 	rootCmd.AddCommand(versionCmd)
 }
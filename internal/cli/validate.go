@@ -11,7 +11,7 @@ var validateCmd = &cobra.Command{
 	Use:   "validate <config.yml>",
 	Short: "Validate YAML configuration",
 	Args:  cobra.ExactArgs(1),
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		configPath := args[0]
 
 		// 1. 設定読み込み
@@ -20,7 +20,10 @@ var validateCmd = &cobra.Command{
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		// 2. バリデーション
+		// 2. バリデーション（--route / --tag で選択したルートのみ）
+		if err := selectRoutes(cmd, cfg); err != nil {
+			return err
+		}
 		if err := config.Validate(cfg); err != nil {
 			return fmt.Errorf("validation failed: %w", err)
 		}
@@ -29,3 +32,7 @@ var validateCmd = &cobra.Command{
 		return nil
 	},
 }
+
+func init() {
+	addRouteFlags(validateCmd)
+}
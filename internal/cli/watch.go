@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/JHashimoto0518/ttlx/internal/generator"
+	"github.com/JHashimoto0518/ttlx/internal/watcher"
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <config.yml>",
+	Short: "Rebuild TTL scripts whenever the configuration changes",
+	Long: `watch builds the TTL scripts and rebuilds them each time the configuration
+file is saved. Only scripts whose content changed are rewritten, and
+validation errors are reported without stopping. Press Ctrl+C to stop.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := args[0]
+		outputPath, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return fmt.Errorf("failed to get output flag: %w", err)
+		}
+		reproducible, err := cmd.Flags().GetBool("reproducible")
+		if err != nil {
+			return fmt.Errorf("failed to get reproducible flag: %w", err)
+		}
+		sourceHash, err := cmd.Flags().GetBool("source-hash")
+		if err != nil {
+			return fmt.Errorf("failed to get source-hash flag: %w", err)
+		}
+		debounce, err := cmd.Flags().GetDuration("debounce")
+		if err != nil {
+			return fmt.Errorf("failed to get debounce flag: %w", err)
+		}
+		format, err := outputFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		outputDir := "."
+		if outputPath != "" {
+			outputDir = outputPath
+		}
+
+		w := watcher.New(configPath, outputDir, cmd.OutOrStdout())
+		w.Debounce = debounce
+		w.Output = format
+		w.Options = func() (generator.Options, error) {
+			return headerOptions(configPath, reproducible, sourceHash)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		return w.Run(ctx)
+	},
+}
+
+func init() {
+	watchCmd.Flags().StringP("output", "o", "", "Output directory path")
+	watchCmd.Flags().Bool("reproducible", false, "Omit the generation timestamp (SOURCE_DATE_EPOCH pins it instead)")
+	watchCmd.Flags().Bool("source-hash", false, "Embed the SHA-256 of the config file instead of the generation timestamp")
+	watchCmd.Flags().String("encoding", "", "Output encoding: utf8, utf8-bom, or sjis (default: options.output.encoding or utf8)")
+	watchCmd.Flags().String("eol", "", "Output line endings: lf or crlf (default: options.output.eol or lf)")
+	watchCmd.Flags().Duration("debounce", watcher.DefaultDebounce, "Wait until the config file has not changed for this long before rebuilding")
+}
@@ -0,0 +1,68 @@
+package config
+
+import "sort"
+
+// DevicePreset represents the default behavior of a device type.
+type DevicePreset struct {
+	PromptMarker      string   // 特権モードのプロンプト文字列（デフォルト値）
+	LoginPrompt       string   // 特権モード移行前のプロンプト文字列（空の場合はPromptMarkerと同じ）
+	InitCommands      []string // ログイン直後に実行するコマンド（ページング無効化など）
+	EnableCommand     string   // 特権モード移行コマンド（空の場合は非対応）
+	EnablePrompt      string   // 特権パスワード入力待機文字列
+	DisconnectCommand string   // 切断コマンド
+	PagerPrompt       string   // ページングの継続待ち文字列（セッション単位でページングを無効化できない機種のみ）
+}
+
+// DefaultDeviceType is the device type used when device_type is omitted.
+const DefaultDeviceType = "linux"
+
+var devicePresets = map[string]*DevicePreset{
+	"linux": {
+		DisconnectCommand: "exit",
+	},
+	"cisco_ios": {
+		PromptMarker:      "#",
+		LoginPrompt:       ">",
+		InitCommands:      []string{"terminal length 0"},
+		EnableCommand:     "enable",
+		EnablePrompt:      "Password:",
+		DisconnectCommand: "logout", // 特権モードからもセッションを終了
+	},
+	"cisco_nxos": {
+		PromptMarker:      "#",
+		InitCommands:      []string{"terminal length 0"},
+		DisconnectCommand: "exit",
+	},
+	"junos": {
+		PromptMarker:      "> ",
+		InitCommands:      []string{"set cli screen-length 0"},
+		DisconnectCommand: "quit", // 運用モードのセッションを終了
+	},
+	"fortios": {
+		PromptMarker: " # ",
+		// ページング設定（config system console）は全管理者に影響する保存設定のため変更せず、
+		// --More-- にスペースを送信して出力を読み進める
+		PagerPrompt:       "--More--",
+		DisconnectCommand: "exit",
+	},
+}
+
+// GetDevicePreset returns the preset for the given device type.
+// An empty device type is treated as DefaultDeviceType.
+func GetDevicePreset(deviceType string) (*DevicePreset, bool) {
+	if deviceType == "" {
+		deviceType = DefaultDeviceType
+	}
+	preset, ok := devicePresets[deviceType]
+	return preset, ok
+}
+
+// DeviceTypes returns the supported device type names in sorted order.
+func DeviceTypes() []string {
+	names := make([]string, 0, len(devicePresets))
+	for name := range devicePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -19,6 +19,11 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	return ParseConfig(data)
+}
+
+// ParseConfig parses YAML configuration data, e.g. a config file read from a git revision.
+func ParseConfig(data []byte) (*Config, error) {
 	// YAMLパース
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
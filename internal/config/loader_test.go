@@ -70,3 +70,75 @@ func TestLoadConfig_PreservesCustomValues(t *testing.T) {
 	// カスタムタイムアウトが保持されていることを確認
 	assert.Equal(t, 60, cfg.Options.Timeout)
 }
+
+func TestLoadConfig_Commands(t *testing.T) {
+	cfg, err := LoadConfig("../../test/fixtures/valid/check-exit.yml")
+	require.NoError(t, err)
+
+	route := cfg.Routes["restart-web"].Steps
+	require.Len(t, route, 2)
+
+	// オブジェクト形式のコマンド
+	require.Len(t, route[0].Commands, 1)
+	assert.Equal(t, "sudo -n true", route[0].Commands[0].Run)
+	assert.True(t, route[0].Commands[0].ChecksExit(route[0]))
+
+	// 文字列形式のコマンドはステップの設定に従う
+	assert.True(t, route[1].CheckExit)
+	assert.Equal(t, "systemctl restart nginx", route[1].Commands[0].Run)
+	assert.Nil(t, route[1].Commands[0].CheckExit)
+	assert.True(t, route[1].Commands[0].ChecksExit(route[1]))
+
+	// コマンド単位の設定がステップの設定より優先される
+	assert.False(t, route[1].Commands[1].ChecksExit(route[1]))
+}
+
+func TestLoadConfig_Foreach(t *testing.T) {
+	cfg, err := LoadConfig("../../test/fixtures/valid/foreach.yml")
+	require.NoError(t, err)
+
+	step := cfg.Routes["restart-services"].Steps[1]
+	require.Len(t, step.Commands, 4)
+
+	// 固定リスト
+	loop := step.Commands[0].Foreach
+	require.NotNil(t, loop)
+	assert.Equal(t, []string{"nginx", "app", "worker"}, loop.Items)
+	assert.Equal(t, "service", loop.Var())
+	require.Len(t, loop.Commands, 2)
+	assert.Equal(t, "sudo systemctl restart ${service}", loop.Commands[0].Run)
+
+	// 取得値のリスト
+	loop = step.Commands[3].Foreach
+	require.NotNil(t, loop)
+	assert.Equal(t, "targets", loop.From)
+	assert.Equal(t, ",", loop.Sep())
+
+	// ループ本体のコマンドも含めて列挙
+	assert.Len(t, step.AllCommands(), 8)
+}
+
+func TestLoadConfig_RouteStrategy(t *testing.T) {
+	cfg, err := LoadConfig("../../test/fixtures/valid/proxyjump.yml")
+	require.NoError(t, err)
+
+	// マッピング形式
+	route := cfg.Routes["db-direct"]
+	require.Len(t, route.Steps, 4)
+	assert.True(t, route.ProxyJump())
+	assert.False(t, route.IsJumpStep(0))
+	assert.True(t, route.IsJumpStep(1))
+	assert.True(t, route.IsJumpStep(2))
+	assert.False(t, route.IsJumpStep(3))
+	hops := route.Hops()
+	require.Len(t, hops, 2)
+	assert.Equal(t, "bastion", hops[0].Profile)
+	assert.Equal(t, "db", hops[1].Profile)
+
+	// ステップのリスト形式
+	route = cfg.Routes["db-nested"]
+	require.Len(t, route.Steps, 3)
+	assert.Empty(t, route.Strategy)
+	assert.False(t, route.IsJumpStep(1))
+	assert.Len(t, route.Hops(), 3)
+}
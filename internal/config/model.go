@@ -1,52 +1,367 @@
 package config
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
 // Config represents the entire YAML configuration.
 type Config struct {
-	Version  string                       `yaml:"version"`
-	Profiles map[string]*Profile          `yaml:"profiles"`
-	Routes   map[string][]*RouteStep      `yaml:"routes"`
-	Options  *Options                     `yaml:"options,omitempty"`
+	Version  string              `yaml:"version"`
+	Profiles map[string]*Profile `yaml:"profiles"`
+	Routes   map[string]*Route   `yaml:"routes"`
+	Options  *Options            `yaml:"options,omitempty"`
 }
 
 // Profile represents an SSH connection profile.
 type Profile struct {
-	Host         string `yaml:"host"`
-	Port         int    `yaml:"port,omitempty"`          // デフォルト: 22
-	User         string `yaml:"user"`
-	PromptMarker string `yaml:"prompt_marker"`           // プロンプトを識別する文字列（必須）例: "$ ", "# "
-	Auth         *Auth  `yaml:"auth"`
+	Host         string      `yaml:"host"`
+	Port         int         `yaml:"port,omitempty"` // デフォルト: 22
+	User         string      `yaml:"user"`
+	PromptMarker string      `yaml:"prompt_marker"` // プロンプトを識別する文字列（必須）例: "$ ", "# "
+	Auth         *Auth       `yaml:"auth"`
+	DeviceType   string      `yaml:"device_type,omitempty"` // "linux"（デフォルト）| "cisco_ios" | "cisco_nxos" | "junos" | "fortios"
+	Enable       *Enable     `yaml:"enable,omitempty"`      // 特権モード移行設定（cisco_ios など）
+	Forwards     []*Forward  `yaml:"forwards,omitempty"`    // ポートフォワーディング設定
+	SSHOptions   *SSHOptions `yaml:"ssh_options,omitempty"` // ssh コマンドのオプション（2段目以降）
+}
+
+// SSHOptions represents options of the ssh command used to connect on later steps.
+// Only forward_agent applies to the first step (Tera Term /ssh-A).
+type SSHOptions struct {
+	IdentityFile string            `yaml:"identity_file,omitempty"` // 前段のホスト上の秘密鍵パス（-i）
+	ForwardAgent bool              `yaml:"forward_agent,omitempty"` // エージェント転送（-A）
+	Options      map[string]string `yaml:"options,omitempty"`       // -o オプション 例: StrictHostKeyChecking: "no"
+	ExtraArgs    []string          `yaml:"extra_args,omitempty"`    // その他の ssh 引数
+}
+
+// Forward represents an SSH port forwarding on a connection.
+type Forward struct {
+	Type        string `yaml:"type"`                   // "local"（-L）| "remote"（-R）| "dynamic"（-D、2段目以降のみ）
+	BindAddress string `yaml:"bind_address,omitempty"` // 待ち受けアドレス（省略時は ssh のデフォルト）
+	BindPort    int    `yaml:"bind_port"`              // 待ち受けポート
+	Host        string `yaml:"host,omitempty"`         // 転送先ホスト（local / remote のみ）
+	Port        int    `yaml:"port,omitempty"`         // 転送先ポート（local / remote のみ）
+}
+
+// Spec returns the forwarding specification in ssh syntax,
+// e.g. "127.0.0.1:8080:intranet:80" or "1080" for dynamic forwarding.
+func (f *Forward) Spec() string {
+	spec := fmt.Sprint(f.BindPort)
+	if f.BindAddress != "" {
+		spec = f.BindAddress + ":" + spec
+	}
+	if f.Type != "dynamic" {
+		spec += fmt.Sprintf(":%s:%d", f.Host, f.Port)
+	}
+	return spec
+}
+
+// Enable represents privileged mode escalation settings for network devices.
+type Enable struct {
+	Value        string `yaml:"value,omitempty"`         // enableパスワード直接記述
+	PasswordFile string `yaml:"password_file,omitempty"` // パスワードファイルパス
 }
 
 // Auth represents authentication settings.
 type Auth struct {
-	Type           string `yaml:"type"`                      // "password" | "keyfile"
-	Value          string `yaml:"value,omitempty"`           // パスワード直接記述
-	PasswordFile   string `yaml:"password_file,omitempty"`   // パスワードファイルパス
-	PasswordPrompt string `yaml:"password_prompt,omitempty"` // パスワード入力待機文字列（2段目以降で必須）例: "password:"
-	Path           string `yaml:"path,omitempty"`            // 秘密鍵ファイルパス
+	Type            string `yaml:"type"`                       // "password" | "keyfile"
+	Value           string `yaml:"value,omitempty"`            // パスワード直接記述
+	PasswordFile    string `yaml:"password_file,omitempty"`    // パスワードファイルパス
+	PasswordPrompt  string `yaml:"password_prompt,omitempty"`  // パスワード入力待機文字列（2段目以降で必須）例: "password:"
+	Path            string `yaml:"path,omitempty"`             // 秘密鍵ファイルパス
+	PassphraseValue string `yaml:"passphrase_value,omitempty"` // 鍵のパスフレーズ直接記述
+	PassphraseFile  string `yaml:"passphrase_file,omitempty"`  // 鍵のパスフレーズを格納したパスワードファイルパス
+	Passphrase      string `yaml:"passphrase,omitempty"`       // "prompt": 実行時に入力ダイアログで取得
+}
+
+// PassphrasePrompt is the prompt ssh shows for an encrypted key on later steps.
+const PassphrasePrompt = "Enter passphrase for key"
+
+// HasPassphrase reports whether the key requires a passphrase.
+func (a *Auth) HasPassphrase() bool {
+	return a.PassphraseValue != "" || a.PassphraseFile != "" || a.Passphrase != ""
+}
+
+// Route represents a connection route.
+// A plain list of steps in YAML is treated as a route with only Steps set.
+type Route struct {
+	Description string       `yaml:"description,omitempty"` // ルートの説明（生成する TTL のヘッダーに出力）
+	Tags        []string     `yaml:"tags,omitempty"`        // ttlx build --tag などでルートを選択するためのタグ
+	Strategy    string       `yaml:"strategy,omitempty"`    // 多段接続の方式: "nested"（デフォルト、1段ずつ ssh を実行）| "proxyjump"（ssh -J で1回の ssh に集約）
+	Keepalive   *Keepalive   `yaml:"keepalive,omitempty"`   // timeout を指定したコマンドの待機中の keepalive 設定
+	Steps       []*RouteStep `yaml:"steps"`
+}
+
+// Keepalive represents keepalive settings used while waiting for long-running commands.
+type Keepalive struct {
+	Interval int    `yaml:"interval"`         // keepalive の間隔（秒）
+	Method   string `yaml:"method,omitempty"` // "nul"（デフォルト、NUL 文字を送信）| "heartbeat"（入力を送信せず Tera Term の SSH ハートビートに任せる）
+}
+
+// SendsInput reports whether keepalive sends input to the session while waiting.
+func (k *Keepalive) SendsInput() bool {
+	return k != nil && k.Method != "heartbeat"
+}
+
+// UnmarshalYAML accepts both a list of steps and a mapping.
+func (r *Route) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		return value.Decode(&r.Steps)
+	}
+
+	type rawRoute Route
+	return value.Decode((*rawRoute)(r))
+}
+
+// ProxyJump reports whether the route connects through its intermediate steps with ssh -J.
+func (r *Route) ProxyJump() bool {
+	return r.Strategy == "proxyjump"
+}
+
+// IsJumpStep reports whether the step at index i (0-based) is an intermediate step
+// collapsed into the -J list of a proxyjump route. Jump steps have no shell session.
+func (r *Route) IsJumpStep(i int) bool {
+	return r.ProxyJump() && i > 0 && i < len(r.Steps)-1
+}
+
+// Hops returns the steps the macro logs in to interactively, in order:
+// every step, or only the first and last steps of a proxyjump route.
+func (r *Route) Hops() []*RouteStep {
+	hops := make([]*RouteStep, 0, len(r.Steps))
+	for i, step := range r.Steps {
+		if !r.IsJumpStep(i) {
+			hops = append(hops, step)
+		}
+	}
+	return hops
 }
 
 // RouteStep represents a step in the connection route.
 type RouteStep struct {
-	Profile  string   `yaml:"profile"`
-	Commands []string `yaml:"commands,omitempty"`
+	Profile   string     `yaml:"profile"`
+	Commands  []*Command `yaml:"commands,omitempty"`
+	CheckExit bool       `yaml:"check_exit,omitempty"` // コマンドの終了コードを確認するか（コマンド単位のデフォルト値）
+	OnFailure string     `yaml:"on_failure,omitempty"` // コマンド失敗時の動作（コマンド単位のデフォルト値）: "abort"（デフォルト）| "continue" | "skip_remaining_commands" | "goto_step"
+	GotoStep  int        `yaml:"goto_step,omitempty"`  // on_failure: goto_step の遷移先ステップ番号（1始まり）
+	When      *Condition `yaml:"when,omitempty"`       // ステップを実行する条件
+	Transfer  *Transfer  `yaml:"transfer,omitempty"`   // コマンド実行前に行うファイル転送
+}
+
+// Transfer represents a file transfer performed in a route step.
+type Transfer struct {
+	Protocol  string `yaml:"protocol,omitempty"` // "scp"（デフォルト）| "zmodem" | "kermit" | "xmodem"
+	Direction string `yaml:"direction"`          // "upload" | "download"
+	Local     string `yaml:"local"`              // ローカル（Tera Term 側）のファイルパス
+	Remote    string `yaml:"remote"`             // リモートのファイルパス
+	Wait      *bool  `yaml:"wait,omitempty"`     // 転送完了を待機するか（scp のみ、デフォルト: true）
+	Checksum  bool   `yaml:"checksum,omitempty"` // 転送後に sha256sum でファイルを照合するか
+}
+
+// ProtocolName returns the transfer protocol, defaulting to "scp".
+func (t *Transfer) ProtocolName() string {
+	if t.Protocol == "" {
+		return "scp"
+	}
+	return t.Protocol
+}
+
+// Waits reports whether the transfer waits for completion.
+// Only SCP transfers run in the background; the other protocols always block until done.
+func (t *Transfer) Waits() bool {
+	return t.ProtocolName() == "scp" && (t.Wait == nil || *t.Wait)
+}
+
+// LocalName returns the file name of the local path, which may use either path separator.
+func (t *Transfer) LocalName() string {
+	return t.Local[strings.LastIndexAny(t.Local, `/\`)+1:]
+}
+
+// LocalDir returns the directory of the local path, or "" if it has none.
+func (t *Transfer) LocalDir() string {
+	if i := strings.LastIndexAny(t.Local, `/\`); i >= 0 {
+		return t.Local[:i]
+	}
+	return ""
+}
+
+// AllCommands returns the step's commands including those nested in foreach loops, in order.
+func (s *RouteStep) AllCommands() []*Command {
+	return flattenCommands(s.Commands)
+}
+
+func flattenCommands(commands []*Command) []*Command {
+	result := make([]*Command, 0, len(commands))
+	for _, cmd := range commands {
+		result = append(result, cmd)
+		if cmd.Foreach != nil {
+			result = append(result, flattenCommands(cmd.Foreach.Commands)...)
+		}
+	}
+	return result
+}
+
+// Command represents a command executed in a route step.
+// A plain string in YAML is treated as a command with only Run set.
+type Command struct {
+	Run               string     `yaml:"run"`
+	CheckExit         *bool      `yaml:"check_exit,omitempty"`          // 終了コードを確認するか（未指定時はステップの設定に従う）
+	CaptureRegex      string     `yaml:"capture_regex,omitempty"`       // 出力から値を取得する正規表現（グループ指定時は1番目のグループ）
+	SaveAs            string     `yaml:"save_as,omitempty"`             // 取得した値を保存する変数名（後続コマンドで ${name} として参照）
+	ExpectContains    string     `yaml:"expect_contains,omitempty"`     // 出力（capture_regex指定時は取得値）に含まれるべき文字列
+	ExpectNotContains string     `yaml:"expect_not_contains,omitempty"` // 出力（capture_regex指定時は取得値）に含まれてはならない文字列
+	OnFailure         string     `yaml:"on_failure,omitempty"`          // コマンド失敗時の動作（未指定時はステップの設定に従う）
+	GotoStep          int        `yaml:"goto_step,omitempty"`           // on_failure: goto_step の遷移先ステップ番号（1始まり）
+	When              *Condition `yaml:"when,omitempty"`                // コマンドを実行する条件
+	Foreach           *Foreach   `yaml:"foreach,omitempty"`             // リストの各要素に対してコマンドを繰り返す（run とは同時指定不可）
+	Timeout           string     `yaml:"timeout,omitempty"`             // コマンド完了を待機する秒数、または "none"（無制限）。未指定時は options.timeout
+	CompletionMarker  string     `yaml:"completion_marker,omitempty"`   // プロンプトの前に完了を示す文字列を待機
+}
+
+// TimeoutNone is the command timeout that waits for completion without a limit.
+const TimeoutNone = "none"
+
+// TimeoutSeconds returns the command's timeout in seconds (0 for "none")
+// and whether the command overrides the global timeout.
+func (c *Command) TimeoutSeconds() (int, bool) {
+	if c.Timeout == "" {
+		return 0, false
+	}
+	if c.Timeout == TimeoutNone {
+		return 0, true
+	}
+	seconds, err := strconv.Atoi(c.Timeout)
+	if err != nil {
+		return 0, false
+	}
+	return seconds, true
+}
+
+// LongRunning reports whether the command overrides how its completion is awaited
+// (timeout or completion_marker).
+func (c *Command) LongRunning() bool {
+	return c.Timeout != "" || c.CompletionMarker != ""
+}
+
+// Foreach represents a loop that runs commands for each item of a list.
+// Exactly one of Items or From must be set.
+type Foreach struct {
+	Items     []string   `yaml:"items,omitempty"`     // 繰り返し対象の値（静的リスト）
+	From      string     `yaml:"from,omitempty"`      // save_as で取得した変数名（実行時に区切り文字で分割）
+	Separator string     `yaml:"separator,omitempty"` // from の区切り文字（デフォルト: ","）
+	As        string     `yaml:"as,omitempty"`        // ループ変数名（デフォルト: "item"）。コマンド内で ${item} として参照
+	Commands  []*Command `yaml:"commands"`
+}
+
+// Var returns the loop variable name.
+func (f *Foreach) Var() string {
+	if f.As == "" {
+		return "item"
+	}
+	return f.As
+}
+
+// Sep returns the separator used to split From.
+func (f *Foreach) Sep() string {
+	if f.Separator == "" {
+		return ","
+	}
+	return f.Separator
+}
+
+// Condition represents a `when` condition on a captured variable or an earlier step's result.
+// Exactly one of Var or Step must be set.
+type Condition struct {
+	Var       string `yaml:"var,omitempty"`        // save_as で取得した変数名
+	Equals    string `yaml:"equals,omitempty"`     // 変数の値が一致する
+	NotEquals string `yaml:"not_equals,omitempty"` // 変数の値が一致しない
+	Contains  string `yaml:"contains,omitempty"`   // 変数の値が文字列を含む
+	Step      int    `yaml:"step,omitempty"`       // 結果を参照するステップ番号（1始まり）
+	Result    string `yaml:"result,omitempty"`     // ステップの結果: "success" | "failure" | "skipped"
+}
+
+// UnmarshalYAML accepts both a plain string and a mapping.
+func (c *Command) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&c.Run)
+	}
+
+	type rawCommand Command
+	return value.Decode((*rawCommand)(c))
+}
+
+// HasAssertion reports whether the command has an expect_contains or expect_not_contains assertion.
+func (c *Command) HasAssertion() bool {
+	return c.ExpectContains != "" || c.ExpectNotContains != ""
+}
+
+// FailurePolicy returns the on_failure policy and goto_step target for the command,
+// falling back to the step's settings and then to "abort".
+func (c *Command) FailurePolicy(step *RouteStep) (string, int) {
+	if c.OnFailure != "" {
+		return c.OnFailure, c.GotoStep
+	}
+	if step.OnFailure != "" {
+		return step.OnFailure, step.GotoStep
+	}
+	return "abort", 0
+}
+
+// ChecksExit reports whether the command's exit status should be checked.
+func (c *Command) ChecksExit(step *RouteStep) bool {
+	if c.CheckExit != nil {
+		return *c.CheckExit
+	}
+	return step.CheckExit
 }
 
 // Options represents global options.
 type Options struct {
-	Timeout        int    `yaml:"timeout,omitempty"`
-	Retry          int    `yaml:"retry,omitempty"`
-	Log            bool   `yaml:"log,omitempty"`
-	LogFile        string `yaml:"log_file,omitempty"`
-	AutoDisconnect *bool  `yaml:"auto_disconnect,omitempty"` // 最終ステップ完了後に自動切断するか（デフォルト: false）
+	Timeout        int     `yaml:"timeout,omitempty"`
+	Retry          int     `yaml:"retry,omitempty"`           // 接続失敗時のリトライ回数（デフォルト: 0 = リトライなし）
+	RetryInterval  int     `yaml:"retry_interval,omitempty"`  // リトライ前の待機秒数（デフォルト: 5）
+	RetryBackoff   int     `yaml:"retry_backoff,omitempty"`   // リトライごとの待機秒数の倍率（デフォルト: 1 = 固定間隔）
+	Log            bool    `yaml:"log,omitempty"`             // セッションログを記録するか（デフォルト: false）
+	LogFile        string  `yaml:"log_file,omitempty"`        // ログファイルパス（{route}, {date}, {time} を置換）
+	LogAppend      bool    `yaml:"log_append,omitempty"`      // 既存ログファイルに追記するか（デフォルト: false）
+	LogTimestamp   bool    `yaml:"log_timestamp,omitempty"`   // 各行にタイムスタンプを付与するか（デフォルト: false）
+	AutoDisconnect *bool   `yaml:"auto_disconnect,omitempty"` // 最終ステップ完了後に自動切断するか（デフォルト: false）
+	Output         *Output `yaml:"output,omitempty"`          // 生成する TTL ファイルの文字コード・改行コード
+}
+
+// Output represents the character encoding and line endings of the generated TTL files.
+type Output struct {
+	Encoding string `yaml:"encoding,omitempty"` // "utf8"（デフォルト）| "utf8-bom" | "sjis"
+	EOL      string `yaml:"eol,omitempty"`      // "lf"（デフォルト）| "crlf"
 }
 
+// Output encodings and line endings.
+const (
+	EncodingUTF8    = "utf8"
+	EncodingUTF8BOM = "utf8-bom"
+	EncodingSJIS    = "sjis"
+	EOLLF           = "lf"
+	EOLCRLF         = "crlf"
+)
+
 // SetDefaults sets default values for the config.
 func (c *Config) SetDefaults() {
 	for _, profile := range c.Profiles {
 		if profile.Port == 0 {
 			profile.Port = 22
 		}
+		// デバイス種別のプリセットからプロンプトを補完
+		if profile.PromptMarker == "" {
+			if preset, ok := GetDevicePreset(profile.DeviceType); ok {
+				profile.PromptMarker = preset.PromptMarker
+				if profile.Enable == nil && preset.LoginPrompt != "" {
+					profile.PromptMarker = preset.LoginPrompt
+				}
+			}
+		}
 	}
 
 	if c.Options == nil {
@@ -59,4 +374,34 @@ func (c *Config) SetDefaults() {
 		defaultAutoDisconnect := false
 		c.Options.AutoDisconnect = &defaultAutoDisconnect
 	}
+	if c.Options.Output == nil {
+		c.Options.Output = &Output{}
+	}
+	if c.Options.Output.Encoding == "" {
+		c.Options.Output.Encoding = EncodingUTF8
+	}
+	if c.Options.Output.EOL == "" {
+		c.Options.Output.EOL = EOLLF
+	}
+}
+
+// LoginPrompt returns the prompt expected right after login.
+// When privileged mode escalation is configured, the device preset's
+// unprivileged prompt is returned instead of PromptMarker.
+func (p *Profile) LoginPrompt() string {
+	if p.Enable != nil {
+		if preset, ok := GetDevicePreset(p.DeviceType); ok && preset.LoginPrompt != "" {
+			return preset.LoginPrompt
+		}
+	}
+	return p.PromptMarker
+}
+
+// PagerPrompt returns the pager prompt of the profile's device type (e.g. --More--),
+// or "" when the device's output is not paged.
+func (p *Profile) PagerPrompt() string {
+	if preset, ok := GetDevicePreset(p.DeviceType); ok {
+		return preset.PagerPrompt
+	}
+	return ""
 }
@@ -42,6 +42,7 @@ func TestConfig_SetDefaults(t *testing.T) {
 				Options: &Options{
 					Timeout:        30,
 					AutoDisconnect: boolPtr(false),
+					Output:         &Output{Encoding: EncodingUTF8, EOL: EOLLF},
 				},
 			},
 		},
@@ -71,6 +72,7 @@ func TestConfig_SetDefaults(t *testing.T) {
 				Options: &Options{
 					Timeout:        30,
 					AutoDisconnect: boolPtr(false),
+					Output:         &Output{Encoding: EncodingUTF8, EOL: EOLLF},
 				},
 			},
 		},
@@ -99,6 +101,7 @@ func TestConfig_SetDefaults(t *testing.T) {
 				Options: &Options{
 					Timeout:        30,
 					AutoDisconnect: boolPtr(false),
+					Output:         &Output{Encoding: EncodingUTF8, EOL: EOLLF},
 				},
 			},
 		},
@@ -131,6 +134,7 @@ func TestConfig_SetDefaults(t *testing.T) {
 				Options: &Options{
 					Timeout:        60,
 					AutoDisconnect: boolPtr(false),
+					Output:         &Output{Encoding: EncodingUTF8, EOL: EOLLF},
 				},
 			},
 		},
@@ -209,3 +213,59 @@ func TestConfig_SetDefaults_AutoDisconnect(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_SetDefaults_DevicePrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  *Profile
+		expected string
+	}{
+		{
+			name:     "cisco_ios without enable uses login prompt",
+			profile:  &Profile{DeviceType: "cisco_ios"},
+			expected: ">",
+		},
+		{
+			name:     "cisco_ios with enable uses privileged prompt",
+			profile:  &Profile{DeviceType: "cisco_ios", Enable: &Enable{}},
+			expected: "#",
+		},
+		{
+			name:     "junos preset prompt",
+			profile:  &Profile{DeviceType: "junos"},
+			expected: "> ",
+		},
+		{
+			name:     "custom prompt_marker is preserved",
+			profile:  &Profile{DeviceType: "junos", PromptMarker: "% "},
+			expected: "% ",
+		},
+		{
+			name:     "linux has no default prompt",
+			profile:  &Profile{},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Profiles: map[string]*Profile{"test": tt.profile}}
+			cfg.SetDefaults()
+			assert.Equal(t, tt.expected, tt.profile.PromptMarker)
+		})
+	}
+}
+
+func TestProfile_LoginPrompt(t *testing.T) {
+	profile := &Profile{DeviceType: "cisco_ios", PromptMarker: "#", Enable: &Enable{}}
+	assert.Equal(t, ">", profile.LoginPrompt())
+
+	profile.Enable = nil
+	assert.Equal(t, "#", profile.LoginPrompt())
+}
+
+func TestProfile_PagerPrompt(t *testing.T) {
+	assert.Equal(t, "--More--", (&Profile{DeviceType: "fortios"}).PagerPrompt())
+	assert.Equal(t, "", (&Profile{DeviceType: "cisco_ios"}).PagerPrompt())
+	assert.Equal(t, "", (&Profile{}).PagerPrompt())
+}
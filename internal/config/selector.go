@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// SelectRoutes returns the sorted names of the routes matching the filters.
+// A route is selected when its name matches one of patterns (glob, e.g. "prod-*")
+// and it has one of tags; an empty filter matches every route.
+// A pattern or tag that matches no route is an error, so that typos are not ignored.
+func SelectRoutes(cfg *Config, patterns, tags []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid route pattern: %s", pattern)
+		}
+	}
+
+	names := make([]string, 0, len(cfg.Routes))
+	for name := range cfg.Routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, pattern := range patterns {
+		if !anyRoute(names, func(name string) bool { return matchRoute(name, []string{pattern}) }) {
+			return nil, fmt.Errorf("no route matches: %s", pattern)
+		}
+	}
+	for _, tag := range tags {
+		if !anyRoute(names, func(name string) bool { return hasTag(cfg.Routes[name], []string{tag}) }) {
+			return nil, fmt.Errorf("no route has tag: %s", tag)
+		}
+	}
+
+	var selected []string
+	for _, name := range names {
+		if (len(patterns) == 0 || matchRoute(name, patterns)) && (len(tags) == 0 || hasTag(cfg.Routes[name], tags)) {
+			selected = append(selected, name)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no route matches both the route patterns and tags")
+	}
+	return selected, nil
+}
+
+// KeepRoutes removes every route of cfg except the named ones.
+func KeepRoutes(cfg *Config, names []string) {
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+	for name := range cfg.Routes {
+		if !keep[name] {
+			delete(cfg.Routes, name)
+		}
+	}
+}
+
+func anyRoute(names []string, match func(string) bool) bool {
+	for _, name := range names {
+		if match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRoute(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		// パターンは事前に検証済みのためエラーは発生しない
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTag(route *Route, tags []string) bool {
+	if route == nil {
+		return false
+	}
+	for _, want := range tags {
+		for _, tag := range route.Tags {
+			if tag == want {
+				return true
+			}
+		}
+	}
+	return false
+}
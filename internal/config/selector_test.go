@@ -0,0 +1,103 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectRoutes(t *testing.T) {
+	cfg, err := LoadConfig("../../test/fixtures/valid/route-metadata.yml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		patterns []string
+		tags     []string
+		expected []string
+		errMsg   string
+	}{
+		{
+			name:     "no filters",
+			expected: []string{"maintenance", "prod-db", "prod-web", "stg-web"},
+		},
+		{
+			name:     "exact name",
+			patterns: []string{"prod-db"},
+			expected: []string{"prod-db"},
+		},
+		{
+			name:     "glob",
+			patterns: []string{"prod-*"},
+			expected: []string{"prod-db", "prod-web"},
+		},
+		{
+			name:     "several patterns",
+			patterns: []string{"stg-*", "maintenance"},
+			expected: []string{"maintenance", "stg-web"},
+		},
+		{
+			name:     "tag",
+			tags:     []string{"web"},
+			expected: []string{"prod-web", "stg-web"},
+		},
+		{
+			name:     "several tags match any",
+			tags:     []string{"db", "staging"},
+			expected: []string{"prod-db", "stg-web"},
+		},
+		{
+			name:     "patterns and tags both apply",
+			patterns: []string{"prod-*"},
+			tags:     []string{"web"},
+			expected: []string{"prod-web"},
+		},
+		{
+			name:     "pattern matches nothing",
+			patterns: []string{"dev-*"},
+			errMsg:   "no route matches: dev-*",
+		},
+		{
+			name:   "unknown tag",
+			tags:   []string{"dev"},
+			errMsg: "no route has tag: dev",
+		},
+		{
+			name:     "empty intersection",
+			patterns: []string{"stg-*"},
+			tags:     []string{"db"},
+			errMsg:   "no route matches both the route patterns and tags",
+		},
+		{
+			name:     "invalid pattern",
+			patterns: []string{"prod-["},
+			errMsg:   "invalid route pattern: prod-[",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := SelectRoutes(cfg, tt.patterns, tt.tags)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func TestKeepRoutes(t *testing.T) {
+	cfg, err := LoadConfig("../../test/fixtures/valid/route-metadata.yml")
+	require.NoError(t, err)
+
+	KeepRoutes(cfg, []string{"prod-db", "stg-web"})
+
+	assert.Len(t, cfg.Routes, 2)
+	assert.Contains(t, cfg.Routes, "prod-db")
+	assert.Contains(t, cfg.Routes, "stg-web")
+	assert.Equal(t, []string{"prod", "db"}, cfg.Routes["prod-db"].Tags)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"path"
 	"regexp"
 	"strings"
 )
@@ -30,36 +31,118 @@ func Validate(config *Config) error {
 		}
 
 		// ルート名が有効なファイル名か
-		if !isValidFileName(routeName) {
+		if !IsValidFileName(routeName) {
 			return fmt.Errorf("route name '%s' contains invalid characters. Use only alphanumeric, hyphens, and underscores", routeName)
 		}
 
 		// ルートが空
-		if len(route) == 0 {
+		if route == nil || len(route.Steps) == 0 {
 			return fmt.Errorf("route '%s' must have at least one step", routeName)
 		}
+		steps := route.Steps
 
 		// プロファイル参照チェック
-		for i, step := range route {
+		for i, step := range steps {
 			if _, ok := config.Profiles[step.Profile]; !ok {
 				return fmt.Errorf("route '%s': profile '%s' not found (step %d)", routeName, step.Profile, i+1)
 			}
 		}
 
+		// タグチェック
+		for _, tag := range route.Tags {
+			if !tagPattern.MatchString(tag) {
+				return fmt.Errorf("route '%s': invalid tag: '%s'. Use only alphanumeric, hyphens, underscores, and dots", routeName, tag)
+			}
+		}
+
+		// keepalive 設定チェック
+		if route.Keepalive != nil {
+			if err := validateKeepalive(route.Keepalive); err != nil {
+				return fmt.Errorf("route '%s': keepalive: %w", routeName, err)
+			}
+		}
+
+		// コマンド設定チェック
+		for i, step := range steps {
+			if err := validateCommands(config.Profiles[step.Profile], step); err != nil {
+				return fmt.Errorf("route '%s': step %d: %w", routeName, i+1, err)
+			}
+		}
+
+		// 多段接続の方式チェック（コマンドを参照するため、コマンド設定チェックの後に実行）
+		if err := validateStrategy(route, config.Profiles); err != nil {
+			return fmt.Errorf("route '%s': %w", routeName, err)
+		}
+
+		// 次段への接続は Unix の ssh コマンドを前提とするため、ネットワーク機器は最終ステップのみ
+		for i, step := range steps[:len(steps)-1] {
+			if route.IsJumpStep(i) {
+				continue // ジャンプホストは validateStrategy でチェック済み
+			}
+			if deviceType := config.Profiles[step.Profile].DeviceType; deviceType != "" && deviceType != DefaultDeviceType {
+				return fmt.Errorf("route '%s': profile '%s': device_type '%s' is only supported on the last step (step %d connects to the next step with ssh)", routeName, step.Profile, deviceType, i+1)
+			}
+		}
+
+		// ファイル転送チェック
+		for i, step := range steps {
+			if step.Transfer == nil {
+				continue
+			}
+			if err := validateTransfer(config.Profiles[step.Profile], step.Transfer, i+1); err != nil {
+				return fmt.Errorf("route '%s': step %d: transfer: %w", routeName, i+1, err)
+			}
+		}
+
+		// when / on_failure チェック
+		if err := validateFlowControl(steps); err != nil {
+			return fmt.Errorf("route '%s': %w", routeName, err)
+		}
+
 		// 2段目以降のpassword_promptチェック
-		for i, step := range route {
-			if i == 0 {
-				continue // 1段目はconnectコマンドを使用するためpassword_prompt不要
+		previous := config.Profiles[steps[0].Profile] // ssh コマンドを実行するホストのプロファイル
+		for i, step := range steps {
+			if i == 0 || route.IsJumpStep(i) {
+				continue // 1段目はconnectコマンドを使用し、ジャンプホストは1段目の ssh が認証するため対象外
 			}
 			profile := config.Profiles[step.Profile]
 			if profile.Auth.Type == "password" && profile.Auth.PasswordPrompt == "" {
 				return fmt.Errorf("route '%s': profile '%s': password_prompt is required for password auth in route step %d", routeName, step.Profile, i+1)
 			}
+
+			// 2段目以降の鍵認証は前段のホスト上の秘密鍵（またはエージェント転送）が必要
+			if profile.Auth.Type == "keyfile" && (profile.SSHOptions == nil || profile.SSHOptions.IdentityFile == "") {
+				if previous.SSHOptions == nil || !previous.SSHOptions.ForwardAgent {
+					return fmt.Errorf("route '%s': profile '%s': keyfile auth in route step %d requires ssh_options.identity_file (key path on the previous host) or ssh_options.forward_agent on the previous step's profile", routeName, step.Profile, i+1)
+				}
+			}
+			previous = profile
 		}
 	}
 
 	// プロファイル設定チェック
 	for name, profile := range config.Profiles {
+		// プロファイル名チェック（TTL のラベル・コメント・パスワード名に使用）
+		if !IsValidProfileName(name) {
+			return fmt.Errorf("profile name '%s' contains invalid characters. Use only alphanumeric, hyphens, underscores, and dots", name)
+		}
+
+		// デバイス種別チェック
+		preset, ok := GetDevicePreset(profile.DeviceType)
+		if !ok {
+			return fmt.Errorf("profile '%s': invalid device_type: %s (must be one of: %s)", name, profile.DeviceType, strings.Join(DeviceTypes(), ", "))
+		}
+
+		// 特権モード設定チェック
+		if profile.Enable != nil {
+			if preset.EnableCommand == "" {
+				return fmt.Errorf("profile '%s': enable is not supported for device_type '%s'", name, profile.DeviceType)
+			}
+			if err := validateEnable(profile.Enable); err != nil {
+				return fmt.Errorf("invalid enable in profile '%s': %w", name, err)
+			}
+		}
+
 		// prompt_marker必須チェック
 		if profile.PromptMarker == "" {
 			return fmt.Errorf("profile '%s': prompt_marker is required", name)
@@ -75,9 +158,55 @@ func Validate(config *Config) error {
 			return fmt.Errorf("profile '%s': password_prompt should not be set for keyfile auth", name)
 		}
 
-		// password_promptにシングルクォートが含まれる場合はエラー（TTLインジェクション対策）
-		if profile.Auth.PasswordPrompt != "" && strings.Contains(profile.Auth.PasswordPrompt, "'") {
-			return fmt.Errorf("profile '%s': password_prompt cannot contain single quotes", name)
+		// ssh オプションチェック
+		if profile.SSHOptions != nil {
+			if err := validateSSHOptions(profile.SSHOptions); err != nil {
+				return fmt.Errorf("profile '%s': invalid ssh_options: %w", name, err)
+			}
+		}
+
+		// ポートフォワーディング設定チェック
+		for i, forward := range profile.Forwards {
+			if err := validateForward(forward); err != nil {
+				return fmt.Errorf("profile '%s': forward %d: %w", name, i+1, err)
+			}
+		}
+	}
+
+	// ルート内のポートフォワーディングチェック（プロファイル設定の検証後）
+	for routeName, route := range config.Routes {
+		if err := validateRouteForwards(route, config.Profiles); err != nil {
+			return fmt.Errorf("route '%s': %w", routeName, err)
+		}
+	}
+
+	// リトライ設定チェック
+	if config.Options != nil {
+		if config.Options.Retry < 0 {
+			return errors.New("options: retry must be 0 or greater")
+		}
+		if config.Options.RetryInterval < 0 {
+			return errors.New("options: retry_interval must be 0 or greater")
+		}
+		if config.Options.RetryBackoff < 0 {
+			return errors.New("options: retry_backoff must be 0 or greater")
+		}
+	}
+
+	// ログ設定チェック
+	if config.Options != nil && config.Options.LogFile != "" {
+		if !config.Options.Log {
+			return errors.New("options: log_file requires log: true")
+		}
+		if err := validateLogFile(config.Options.LogFile); err != nil {
+			return fmt.Errorf("options: invalid log_file: %w", err)
+		}
+	}
+
+	// 出力形式チェック
+	if config.Options != nil && config.Options.Output != nil {
+		if err := validateOutput(config.Options.Output); err != nil {
+			return fmt.Errorf("options: invalid output: %w", err)
 		}
 	}
 
@@ -95,6 +224,10 @@ func validateAuth(auth *Auth) error {
 
 	switch auth.Type {
 	case "password":
+		if auth.HasPassphrase() {
+			return errors.New("password auth: passphrase settings require keyfile auth")
+		}
+
 		// デフォルト値設定: value と password_file が両方空の場合、password_file にデフォルト値を設定
 		if auth.Value == "" && auth.PasswordFile == "" {
 			auth.PasswordFile = "passwords.dat"
@@ -108,6 +241,21 @@ func validateAuth(auth *Auth) error {
 		if auth.Path == "" {
 			return errors.New("keyfile auth requires 'path'")
 		}
+
+		if auth.Passphrase != "" && auth.Passphrase != "prompt" {
+			return fmt.Errorf("keyfile auth: invalid passphrase: %s (must be 'prompt')", auth.Passphrase)
+		}
+
+		// 相互排他性チェック: passphrase_value / passphrase_file / passphrase: prompt は1つのみ指定可能
+		specified := 0
+		for _, set := range []bool{auth.PassphraseValue != "", auth.PassphraseFile != "", auth.Passphrase != ""} {
+			if set {
+				specified++
+			}
+		}
+		if specified > 1 {
+			return errors.New("keyfile auth: 'passphrase_value', 'passphrase_file', and 'passphrase' are mutually exclusive")
+		}
 	default:
 		return fmt.Errorf("invalid auth type: %s (must be 'password' or 'keyfile')", auth.Type)
 	}
@@ -115,9 +263,558 @@ func validateAuth(auth *Auth) error {
 	return nil
 }
 
-// isValidFileName はファイル名として有効な文字列かチェック
-func isValidFileName(name string) bool {
+func validateCommands(profile *Profile, step *RouteStep) error {
+	return validateCommandList(profile, step, step.Commands)
+}
+
+func validateCommandList(profile *Profile, step *RouteStep, commands []*Command) error {
+	for j, cmd := range commands {
+		if cmd == nil {
+			return fmt.Errorf("command %d: run is required", j+1)
+		}
+
+		// foreach はループ本体のコマンドを再帰的にチェック
+		if cmd.Foreach != nil {
+			if err := validateForeach(profile, step, cmd); err != nil {
+				return fmt.Errorf("command %d: foreach: %w", j+1, err)
+			}
+			continue
+		}
+
+		if cmd.Run == "" {
+			return fmt.Errorf("command %d: run is required", j+1)
+		}
+
+		if err := validateCapture(cmd); err != nil {
+			return fmt.Errorf("command %d: %w", j+1, err)
+		}
+
+		if err := validateCompletion(cmd); err != nil {
+			return fmt.Errorf("command %d: %w", j+1, err)
+		}
+
+		// 終了コード確認はUnixシェル（$?）が前提のため linux のみ対応
+		if cmd.ChecksExit(step) && profile.DeviceType != "" && profile.DeviceType != DefaultDeviceType {
+			return fmt.Errorf("command %d: check_exit is not supported for device_type '%s'", j+1, profile.DeviceType)
+		}
+	}
+
+	return nil
+}
+
+func validateForeach(profile *Profile, step *RouteStep, cmd *Command) error {
+	// foreach と同時に指定できるのは when のみ
+	if cmd.Run != "" || cmd.CaptureRegex != "" || cmd.SaveAs != "" || cmd.HasAssertion() ||
+		cmd.CheckExit != nil || cmd.OnFailure != "" || cmd.GotoStep != 0 || cmd.LongRunning() {
+		return errors.New("cannot be combined with other command settings except 'when'")
+	}
+
+	loop := cmd.Foreach
+	if (len(loop.Items) == 0) == (loop.From == "") {
+		return errors.New("exactly one of 'items' and 'from' must be set")
+	}
+	if !saveAsPattern.MatchString(loop.Var()) {
+		return fmt.Errorf("as '%s' must start with a letter and contain only alphanumeric and underscores (max 28 characters)", loop.Var())
+	}
+	if len(loop.Commands) == 0 {
+		return errors.New("commands must have at least one command")
+	}
+
+	return validateCommandList(profile, step, loop.Commands)
+}
+
+// validateStrategy validates the route's connection strategy. Intermediate steps of a
+// proxyjump route are only used as -J jump hosts, so they cannot run anything and
+// cannot be the target of goto_step or when.step.
+func validateStrategy(route *Route, profiles map[string]*Profile) error {
+	switch route.Strategy {
+	case "", "nested":
+		return nil
+	case "proxyjump":
+	default:
+		return fmt.Errorf("invalid strategy: %s (must be 'nested' or 'proxyjump')", route.Strategy)
+	}
+
+	if len(route.Steps) < 3 {
+		return errors.New("strategy 'proxyjump' requires at least one intermediate step")
+	}
+
+	for i, step := range route.Steps {
+		stepNum := i + 1
+		if route.IsJumpStep(i) {
+			if err := validateJumpStep(profiles[step.Profile], step); err != nil {
+				return fmt.Errorf("step %d: proxyjump: %w", stepNum, err)
+			}
+			continue
+		}
+
+		// ジャンプホストにはステップのラベルがないため、遷移先・条件として参照不可
+		jumpStep := func(n int) bool { return n > 0 && route.IsJumpStep(n-1) }
+		if jumpStep(step.GotoStep) || (step.When != nil && jumpStep(step.When.Step)) {
+			return fmt.Errorf("step %d: cannot refer to proxyjump step", stepNum)
+		}
+		for _, cmd := range step.AllCommands() {
+			if jumpStep(cmd.GotoStep) || (cmd.When != nil && jumpStep(cmd.When.Step)) {
+				return fmt.Errorf("step %d: command '%s': cannot refer to proxyjump step", stepNum, cmd.Run)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateJumpStep validates an intermediate step of a proxyjump route.
+// The jump host is authenticated by the ssh command on the first step's host,
+// with its default keys or a forwarded agent.
+func validateJumpStep(profile *Profile, step *RouteStep) error {
+	if len(step.Commands) > 0 || step.Transfer != nil {
+		return errors.New("jump steps cannot have commands or transfer")
+	}
+	if step.When != nil || step.OnFailure != "" || step.GotoStep != 0 || step.CheckExit {
+		return errors.New("jump steps cannot have when, on_failure, goto_step, or check_exit")
+	}
+	if profile.Auth == nil || profile.Auth.Type != "keyfile" || profile.Auth.HasPassphrase() {
+		return fmt.Errorf("profile '%s': jump hosts require keyfile auth without passphrase", step.Profile)
+	}
+	if profile.DeviceType != "" && profile.DeviceType != "linux" {
+		return fmt.Errorf("profile '%s': device_type '%s' cannot be a jump host", step.Profile, profile.DeviceType)
+	}
+	if len(profile.Forwards) > 0 || profile.SSHOptions != nil {
+		return fmt.Errorf("profile '%s': forwards and ssh_options are not supported on jump hosts", step.Profile)
+	}
+	return nil
+}
+
+func validateFlowControl(route []*RouteStep) error {
+	captured := make(map[string]bool) // それ以前のコマンドで save_as により取得される変数名
+
+	for i, step := range route {
+		if step.When != nil {
+			// スキップしたステップを経由して後続のステップに接続することはできないため、最後のステップのみ許可
+			if i < len(route)-1 {
+				return fmt.Errorf("step %d: when is only allowed on the last step (step %d connects through this step)", i+1, i+2)
+			}
+			if err := validateCondition(step.When, i+1, captured); err != nil {
+				return fmt.Errorf("step %d: when: %w", i+1, err)
+			}
+		}
+		if err := validateFailurePolicy(step.OnFailure, step.GotoStep, i+1, len(route)); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+
+		if err := validateCommandFlow(step, step.Commands, i+1, len(route), captured, false); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func validateCommandFlow(step *RouteStep, commands []*Command, stepNum, routeSteps int, captured map[string]bool, inLoop bool) error {
+	for j, cmd := range commands {
+		if cmd.When != nil {
+			if err := validateCondition(cmd.When, stepNum, captured); err != nil {
+				return fmt.Errorf("command %d: when: %w", j+1, err)
+			}
+		}
+		if err := validateFailurePolicy(cmd.OnFailure, cmd.GotoStep, stepNum, routeSteps); err != nil {
+			return fmt.Errorf("command %d: %w", j+1, err)
+		}
+
+		// ループ内からループ外への遷移は不可（abort / continue のみ）
+		if policy, _ := cmd.FailurePolicy(step); inLoop && policy != "abort" && policy != "continue" {
+			return fmt.Errorf("command %d: on_failure '%s' is not allowed inside foreach (use 'abort' or 'continue')", j+1, policy)
+		}
+
+		if cmd.Foreach != nil {
+			if cmd.Foreach.From != "" && !captured[cmd.Foreach.From] {
+				return fmt.Errorf("command %d: foreach: from '%s' is not captured by an earlier command (save_as)", j+1, cmd.Foreach.From)
+			}
+
+			// ループ変数はループ本体でのみ参照可能
+			loopCaptured := make(map[string]bool, len(captured)+1)
+			for name := range captured {
+				loopCaptured[name] = true
+			}
+			loopCaptured[cmd.Foreach.Var()] = true
+			if err := validateCommandFlow(step, cmd.Foreach.Commands, stepNum, routeSteps, loopCaptured, true); err != nil {
+				return fmt.Errorf("command %d: foreach: %w", j+1, err)
+			}
+			for name := range loopCaptured {
+				if name != cmd.Foreach.Var() {
+					captured[name] = true
+				}
+			}
+		}
+
+		if cmd.SaveAs != "" {
+			captured[cmd.SaveAs] = true
+		}
+	}
+
+	return nil
+}
+
+func validateFailurePolicy(policy string, gotoStep, stepNum, routeSteps int) error {
+	switch policy {
+	case "", "abort", "continue", "skip_remaining_commands":
+		if gotoStep != 0 {
+			return errors.New("goto_step requires on_failure: goto_step")
+		}
+	case "goto_step":
+		// 後方への遷移は無限ループになり得るため、後続のステップのみ許可
+		if gotoStep <= stepNum || gotoStep > routeSteps {
+			return fmt.Errorf("goto_step must be a later step in the route (%d-%d), got %d", stepNum+1, routeSteps, gotoStep)
+		}
+		// 途中のステップを飛ばすと、後続のステップが経由する接続が確立されない
+		if gotoStep > stepNum+1 {
+			return fmt.Errorf("goto_step must be the next step (%d), got %d: step %d connects through the skipped steps", stepNum+1, gotoStep, gotoStep)
+		}
+	default:
+		return fmt.Errorf("invalid on_failure: %s (must be 'abort', 'continue', 'skip_remaining_commands', or 'goto_step')", policy)
+	}
+
+	return nil
+}
+
+func validateCondition(cond *Condition, stepNum int, captured map[string]bool) error {
+	if (cond.Var == "") == (cond.Step == 0) {
+		return errors.New("exactly one of 'var' and 'step' must be set")
+	}
+
+	if cond.Step != 0 {
+		if cond.Step < 1 || cond.Step >= stepNum {
+			return fmt.Errorf("step must refer to an earlier step, got %d", cond.Step)
+		}
+		switch cond.Result {
+		case "success", "failure", "skipped":
+		default:
+			return fmt.Errorf("invalid result: %s (must be 'success', 'failure', or 'skipped')", cond.Result)
+		}
+		return nil
+	}
+
+	if !captured[cond.Var] {
+		return fmt.Errorf("var '%s' is not captured by an earlier command (save_as)", cond.Var)
+	}
+
+	operators := 0
+	for _, value := range []string{cond.Equals, cond.NotEquals, cond.Contains} {
+		if value != "" {
+			operators++
+		}
+	}
+	if operators != 1 {
+		return errors.New("exactly one of 'equals', 'not_equals', and 'contains' must be set")
+	}
+
+	return nil
+}
+
+// saveAsPattern はTTL変数名として使用できる save_as の形式（"cap_" 接頭辞込みで32文字以内）
+var saveAsPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,27}$`)
+
+func validateCapture(cmd *Command) error {
+	if cmd.SaveAs != "" {
+		if cmd.CaptureRegex == "" {
+			return errors.New("save_as requires capture_regex")
+		}
+		if !saveAsPattern.MatchString(cmd.SaveAs) {
+			return fmt.Errorf("save_as '%s' must start with a letter and contain only alphanumeric and underscores (max 28 characters)", cmd.SaveAs)
+		}
+	}
+
+	if cmd.CaptureRegex != "" {
+		if _, err := regexp.Compile(cmd.CaptureRegex); err != nil {
+			return fmt.Errorf("invalid capture_regex: %w", err)
+		}
+	} else if cmd.ExpectContains != "" && cmd.ExpectNotContains != "" {
+		// 出力に対する検証はプロンプトまでの1回の待機で判定するため同時指定不可
+		return errors.New("'expect_contains' and 'expect_not_contains' are mutually exclusive without capture_regex")
+	}
+
+	return nil
+}
+
+// validateCompletion validates how the completion of a long-running command is awaited.
+func validateCompletion(cmd *Command) error {
+	if cmd.Timeout != "" {
+		if seconds, ok := cmd.TimeoutSeconds(); !ok || (seconds <= 0 && cmd.Timeout != TimeoutNone) {
+			return fmt.Errorf("invalid timeout: %s (must be 'none' or a positive number of seconds)", cmd.Timeout)
+		}
+	}
+
+	if !cmd.LongRunning() {
+		return nil
+	}
+
+	// 出力の取得・検証はプロンプトまでの待機で行うため同時指定不可
+	if cmd.CaptureRegex != "" || cmd.HasAssertion() {
+		return errors.New("timeout and completion_marker cannot be combined with capture_regex, expect_contains, or expect_not_contains")
+	}
+
+	return nil
+}
+
+func validateKeepalive(keepalive *Keepalive) error {
+	if keepalive.Interval <= 0 {
+		return errors.New("interval must be greater than 0")
+	}
+
+	switch keepalive.Method {
+	case "", "nul", "heartbeat":
+	default:
+		return fmt.Errorf("invalid method: %s (must be 'nul' or 'heartbeat')", keepalive.Method)
+	}
+
+	return nil
+}
+
+func validateEnable(enable *Enable) error {
+	// デフォルト値設定: value と password_file が両方空の場合、password_file にデフォルト値を設定
+	if enable.Value == "" && enable.PasswordFile == "" {
+		enable.PasswordFile = "passwords.dat"
+	}
+
+	// 相互排他性チェック: value と password_file の同時指定は禁止
+	if enable.Value != "" && enable.PasswordFile != "" {
+		return errors.New("'value' and 'password_file' are mutually exclusive")
+	}
+
+	return nil
+}
+
+// logFilePlaceholders はログファイル名で使用できるプレースホルダー
+var logFilePlaceholders = map[string]bool{
+	"{route}": true,
+	"{date}":  true,
+	"{time}":  true,
+}
+
+func validateLogFile(logFile string) error {
+	for _, placeholder := range regexp.MustCompile(`\{[^}]*\}`).FindAllString(logFile, -1) {
+		if !logFilePlaceholders[placeholder] {
+			return fmt.Errorf("unknown placeholder %s (must be one of: {route}, {date}, {time})", placeholder)
+		}
+	}
+
+	return nil
+}
+
+func validateOutput(output *Output) error {
+	switch output.Encoding {
+	case "", EncodingUTF8, EncodingUTF8BOM, EncodingSJIS:
+	default:
+		return fmt.Errorf("invalid encoding: %s (must be 'utf8', 'utf8-bom', or 'sjis')", output.Encoding)
+	}
+
+	switch output.EOL {
+	case "", EOLLF, EOLCRLF:
+	default:
+		return fmt.Errorf("invalid eol: %s (must be 'lf' or 'crlf')", output.EOL)
+	}
+
+	return nil
+}
+
+// IsValidFileName reports whether name can be used as a route name, which becomes the output file name.
+func IsValidFileName(name string) bool {
 	// 英数字、ハイフン、アンダースコアのみ許可
 	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-]+$`, name)
 	return matched
 }
+
+// profileNamePattern はプロファイル名の形式（ラベル生成時に '-' と '.' は '_' に置換）
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// IsValidProfileName reports whether name can be used as a profile name.
+func IsValidProfileName(name string) bool {
+	return profileNamePattern.MatchString(name)
+}
+
+// tagPattern はルートのタグの形式（--tag で指定するため空白やカンマは不可）
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func validateTransfer(profile *Profile, transfer *Transfer, stepNum int) error {
+	protocol := transfer.ProtocolName()
+	switch protocol {
+	case "scp":
+		// Tera Term の SCP は connect で接続したセッション（1段目）でのみ使用可能
+		if stepNum > 1 {
+			return errors.New("scp is only available on the first step (the direct connect session); use protocol zmodem, kermit, or xmodem")
+		}
+	case "zmodem", "kermit", "xmodem":
+		if transfer.Wait != nil {
+			return fmt.Errorf("wait is only supported for scp (%s transfers always wait for completion)", protocol)
+		}
+	default:
+		return fmt.Errorf("invalid protocol: %s (must be one of: scp, zmodem, kermit, xmodem)", protocol)
+	}
+
+	if transfer.Direction != "upload" && transfer.Direction != "download" {
+		return fmt.Errorf("invalid direction: %s (must be 'upload' or 'download')", transfer.Direction)
+	}
+	if transfer.Local == "" {
+		return errors.New("local is required")
+	}
+	if transfer.Remote == "" {
+		return errors.New("remote is required")
+	}
+
+	// ダブルクォートはリモートのコマンドでのパスのクォートを壊すため禁止
+	if strings.Contains(transfer.Local, `"`) || strings.Contains(transfer.Remote, `"`) {
+		return errors.New("local and remote cannot contain double quotes")
+	}
+
+	// ZMODEM / Kermit の受信ファイルはリモートのファイル名で保存される
+	if transfer.Direction == "download" && (protocol == "zmodem" || protocol == "kermit") {
+		if remoteName := path.Base(transfer.Remote); transfer.LocalName() != remoteName {
+			return fmt.Errorf("%s downloads keep the remote file name; local file name must be '%s'", protocol, remoteName)
+		}
+	}
+
+	// XMODEM はファイル末尾をパディングするためチェックサムが一致しない
+	if transfer.Checksum && protocol == "xmodem" {
+		return errors.New("checksum is not supported for xmodem (files are padded to 128-byte blocks)")
+	}
+	if transfer.Checksum && protocol == "scp" && !transfer.Waits() {
+		return errors.New("checksum requires wait: true for scp")
+	}
+
+	// 完了確認・チェックサム照合はリモートのシェル（stat / sha256sum）を使用するため linux のみ対応
+	if profile.DeviceType != "" && profile.DeviceType != DefaultDeviceType {
+		switch {
+		case transfer.Waits():
+			return fmt.Errorf("wait is not supported for device_type '%s' (set wait: false)", profile.DeviceType)
+		case transfer.Checksum:
+			return fmt.Errorf("checksum is not supported for device_type '%s'", profile.DeviceType)
+		case protocol != "scp":
+			return fmt.Errorf("protocol %s is not supported for device_type '%s'", protocol, profile.DeviceType)
+		}
+	}
+
+	return nil
+}
+
+// sshOptionKeyPattern matches ssh_config keywords for -o options.
+var sshOptionKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+func validateSSHOptions(opts *SSHOptions) error {
+	// ダブルクォートは ssh コマンドでの引数のクォートを壊すため禁止
+	if strings.Contains(opts.IdentityFile, `"`) {
+		return errors.New("identity_file cannot contain double quotes")
+	}
+
+	for key, value := range opts.Options {
+		if !sshOptionKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid option name: %s", key)
+		}
+		if value == "" {
+			return fmt.Errorf("option '%s' requires a value", key)
+		}
+		if strings.Contains(value, `"`) {
+			return fmt.Errorf("option '%s' cannot contain double quotes", key)
+		}
+	}
+
+	for _, arg := range opts.ExtraArgs {
+		if arg == "" {
+			return errors.New("extra_args cannot contain empty arguments")
+		}
+	}
+
+	return nil
+}
+
+// forwardHostPattern matches bind addresses and forwarding destinations
+// (host names, IPv4 addresses, and bracketed IPv6 addresses).
+var forwardHostPattern = regexp.MustCompile(`^(\*|[A-Za-z0-9._-]+|\[[0-9A-Fa-f:.]+\])$`)
+
+func validateForward(forward *Forward) error {
+	if forward == nil {
+		return errors.New("type is required")
+	}
+
+	switch forward.Type {
+	case "local", "remote":
+		if forward.Host == "" {
+			return fmt.Errorf("%s forwarding requires 'host'", forward.Type)
+		}
+		if !forwardHostPattern.MatchString(forward.Host) {
+			return fmt.Errorf("invalid host: %s", forward.Host)
+		}
+		if err := validatePort("port", forward.Port); err != nil {
+			return err
+		}
+	case "dynamic":
+		if forward.Host != "" || forward.Port != 0 {
+			return errors.New("dynamic forwarding does not take 'host' or 'port'")
+		}
+	default:
+		return fmt.Errorf("invalid type: %s (must be 'local', 'remote', or 'dynamic')", forward.Type)
+	}
+
+	if forward.BindAddress != "" && !forwardHostPattern.MatchString(forward.BindAddress) {
+		return fmt.Errorf("invalid bind_address: %s", forward.BindAddress)
+	}
+
+	return validatePort("bind_port", forward.BindPort)
+}
+
+func validatePort(field string, port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%s must be between 1 and 65535 (got %d)", field, port)
+	}
+	return nil
+}
+
+// validateRouteForwards checks the port forwardings of a route's steps.
+// A forwarding listens on the host the ssh client runs on (local/dynamic) or on the
+// host it connects to (remote), so two forwardings collide when they listen on
+// the same host and port.
+func validateRouteForwards(route *Route, profiles map[string]*Profile) error {
+	type listener struct {
+		side    int // 待ち受けるホスト（0: Tera Term の端末、n: n段目のホスト）
+		address string
+		port    int
+		step    int
+	}
+	listeners := make([]listener, 0)
+
+	hop := -1 // ログインするホストの順番（proxyjump のジャンプホストは数えない）
+	for i, step := range route.Steps {
+		profile, ok := profiles[step.Profile]
+		if !ok || route.IsJumpStep(i) {
+			continue
+		}
+		hop++
+		for j, forward := range profile.Forwards {
+			// Tera Term（TTSSH）はダイナミックフォワーディングに非対応
+			if i == 0 && forward.Type == "dynamic" {
+				return fmt.Errorf("step 1: forward %d: dynamic forwarding is not supported on the first step (Tera Term connect)", j+1)
+			}
+
+			current := listener{side: hop, address: forward.BindAddress, port: forward.BindPort, step: i + 1}
+			if forward.Type == "remote" {
+				current.side = hop + 1
+			}
+			for _, other := range listeners {
+				if other.side == current.side && other.port == current.port && bindAddressesOverlap(other.address, current.address) {
+					return fmt.Errorf("step %d: forward %d: bind_port %d collides with a forwarding of step %d", i+1, j+1, forward.BindPort, other.step)
+				}
+			}
+			listeners = append(listeners, current)
+		}
+	}
+
+	return nil
+}
+
+// bindAddressesOverlap reports whether two bind addresses can conflict.
+// An empty or wildcard address listens on all addresses.
+func bindAddressesOverlap(a, b string) bool {
+	wildcard := func(address string) bool {
+		return address == "" || address == "*" || address == "0.0.0.0"
+	}
+	return a == b || wildcard(a) || wildcard(b)
+}
@@ -24,6 +24,34 @@ func TestValidate_Success(t *testing.T) {
 			name: "valid multiple routes config",
 			file: "../../test/fixtures/valid/multiple-routes.yml",
 		},
+		{
+			name: "valid network devices config",
+			file: "../../test/fixtures/valid/network-devices.yml",
+		},
+		{
+			name: "valid transfer config",
+			file: "../../test/fixtures/valid/transfer.yml",
+		},
+		{
+			name: "valid forwards config",
+			file: "../../test/fixtures/valid/forwards.yml",
+		},
+		{
+			name: "valid passphrase config",
+			file: "../../test/fixtures/valid/passphrase.yml",
+		},
+		{
+			name: "valid proxyjump config",
+			file: "../../test/fixtures/valid/proxyjump.yml",
+		},
+		{
+			name: "valid long-running config",
+			file: "../../test/fixtures/valid/long-running.yml",
+		},
+		{
+			name: "valid route metadata config",
+			file: "../../test/fixtures/valid/route-metadata.yml",
+		},
 	}
 
 	for _, tt := range tests {
@@ -100,6 +128,97 @@ func TestValidate_InvalidRouteName(t *testing.T) {
 	assert.Contains(t, err.Error(), "contains invalid characters")
 }
 
+func TestValidate_InvalidProfileName(t *testing.T) {
+	cfg, err := LoadConfig("../../test/fixtures/invalid/invalid-profile-name.yml")
+	require.NoError(t, err)
+
+	err = Validate(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile name 'web server' contains invalid characters")
+}
+
+func TestValidate_ProxyJumpNullCommand(t *testing.T) {
+	cfg, err := LoadConfig("../../test/fixtures/invalid/proxyjump-null-command.yml")
+	require.NoError(t, err)
+
+	err = Validate(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "route 'main': step 3: command 1: run is required")
+}
+
+func TestValidate_InvalidOutputEncoding(t *testing.T) {
+	cfg, err := LoadConfig("../../test/fixtures/invalid/invalid-output-encoding.yml")
+	require.NoError(t, err)
+
+	err = Validate(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "options: invalid output: invalid encoding: euc-jp")
+}
+
+func TestValidate_RouteTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		tags   []string
+		errMsg string
+	}{
+		{name: "no tags"},
+		{name: "valid tags", tags: []string{"prod", "db-1", "team_a", "v1.2"}},
+		{name: "space", tags: []string{"prod db"}, errMsg: "route 'main': invalid tag: 'prod db'"},
+		{name: "comma", tags: []string{"prod,db"}, errMsg: "invalid tag: 'prod,db'"},
+		{name: "empty", tags: []string{""}, errMsg: "invalid tag: ''"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Version: "1.0",
+				Profiles: map[string]*Profile{
+					"server": {Host: "server.example.com", User: "user", PromptMarker: "$ ", Auth: &Auth{Type: "password"}},
+				},
+				Routes: map[string]*Route{
+					"main": {Tags: tt.tags, Steps: []*RouteStep{{Profile: "server"}}},
+				},
+			}
+			cfg.SetDefaults()
+
+			err := Validate(cfg)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output *Output
+		errMsg string
+	}{
+		{name: "defaults", output: &Output{}},
+		{name: "utf8 lf", output: &Output{Encoding: EncodingUTF8, EOL: EOLLF}},
+		{name: "utf8-bom crlf", output: &Output{Encoding: EncodingUTF8BOM, EOL: EOLCRLF}},
+		{name: "sjis", output: &Output{Encoding: EncodingSJIS}},
+		{name: "invalid encoding", output: &Output{Encoding: "shift_jis"}, errMsg: "invalid encoding: shift_jis"},
+		{name: "invalid eol", output: &Output{EOL: "cr"}, errMsg: "invalid eol: cr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOutput(tt.output)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
 func TestValidate_MissingRoute(t *testing.T) {
 	cfg, err := LoadConfig("../../test/fixtures/invalid/empty-route.yml")
 	require.NoError(t, err)
@@ -134,10 +253,10 @@ func TestValidate_PasswordPromptInFirstStep(t *testing.T) {
 				},
 			},
 		},
-		Routes: map[string][]*RouteStep{
-			"test-route": {
+		Routes: map[string]*Route{
+			"test-route": {Steps: []*RouteStep{
 				{Profile: "bastion"},
-			},
+			}},
 		},
 	}
 
@@ -161,10 +280,10 @@ func TestValidate_PasswordPromptOnKeyfileAuth(t *testing.T) {
 				},
 			},
 		},
-		Routes: map[string][]*RouteStep{
-			"test-route": {
+		Routes: map[string]*Route{
+			"test-route": {Steps: []*RouteStep{
 				{Profile: "server"},
-			},
+			}},
 		},
 	}
 
@@ -174,7 +293,7 @@ func TestValidate_PasswordPromptOnKeyfileAuth(t *testing.T) {
 }
 
 func TestValidate_PasswordPromptWithSingleQuote(t *testing.T) {
-	// password_prompt with single quote should be an error (TTL injection prevention)
+	// password_prompt with single quote is allowed (encoded as a TTL string constant)
 	cfg := &Config{
 		Version: "1.0",
 		Profiles: map[string]*Profile{
@@ -191,21 +310,20 @@ func TestValidate_PasswordPromptWithSingleQuote(t *testing.T) {
 				Auth: &Auth{
 					Type:           "password",
 					PasswordFile:   "passwords.dat",
-					PasswordPrompt: "password':", // Single quote should be rejected
+					PasswordPrompt: "password':", // Single quote is encoded by the generator
 				},
 			},
 		},
-		Routes: map[string][]*RouteStep{
-			"test-route": {
+		Routes: map[string]*Route{
+			"test-route": {Steps: []*RouteStep{
 				{Profile: "bastion"},
 				{Profile: "target"},
-			},
+			}},
 		},
 	}
 
 	err := Validate(cfg)
-	require.Error(t, err)
-	assert.Contains(t, err.Error(), "password_prompt cannot contain single quotes")
+	assert.NoError(t, err)
 }
 
 func TestValidate_MultiHopMixedAuth(t *testing.T) {
@@ -224,6 +342,7 @@ func TestValidate_MultiHopMixedAuth(t *testing.T) {
 				User:         "user2",
 				PromptMarker: "$ ",
 				Auth:         &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa"},
+				SSHOptions:   &SSHOptions{IdentityFile: "~/.ssh/id_jump"},
 			},
 			"target": {
 				Host:         "target.internal",
@@ -236,12 +355,12 @@ func TestValidate_MultiHopMixedAuth(t *testing.T) {
 				},
 			},
 		},
-		Routes: map[string][]*RouteStep{
-			"test-route": {
+		Routes: map[string]*Route{
+			"test-route": {Steps: []*RouteStep{
 				{Profile: "bastion"}, // 1st step: password (no password_prompt needed)
 				{Profile: "jump"},    // 2nd step: keyfile (no password_prompt needed)
 				{Profile: "target"},  // 3rd step: password (password_prompt required)
-			},
+			}},
 		},
 	}
 
@@ -369,8 +488,934 @@ func TestIsValidFileName(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isValidFileName(tt.input)
-			assert.Equal(t, tt.expected, result, "isValidFileName(%q) = %v, want %v", tt.input, result, tt.expected)
+			result := IsValidFileName(tt.input)
+			assert.Equal(t, tt.expected, result, "IsValidFileName(%q) = %v, want %v", tt.input, result, tt.expected)
+		})
+	}
+}
+
+func TestValidate_DeviceType(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  *Profile
+		errorMsg string
+	}{
+		{
+			name: "cisco_ios with enable",
+			profile: &Profile{
+				Host:       "10.0.0.1",
+				User:       "admin",
+				DeviceType: "cisco_ios",
+				Auth:       &Auth{Type: "password", PasswordFile: "passwords.dat"},
+				Enable:     &Enable{Value: "secret"},
+			},
+		},
+		{
+			name: "unknown device_type",
+			profile: &Profile{
+				Host:       "10.0.0.1",
+				User:       "admin",
+				DeviceType: "unknown",
+				Auth:       &Auth{Type: "password", PasswordFile: "passwords.dat"},
+			},
+			errorMsg: "invalid device_type: unknown",
+		},
+		{
+			name: "enable on device_type without escalation",
+			profile: &Profile{
+				Host:       "10.0.0.1",
+				User:       "admin",
+				DeviceType: "junos",
+				Auth:       &Auth{Type: "password", PasswordFile: "passwords.dat"},
+				Enable:     &Enable{Value: "secret"},
+			},
+			errorMsg: "enable is not supported for device_type 'junos'",
+		},
+		{
+			name: "enable with both value and password_file",
+			profile: &Profile{
+				Host:       "10.0.0.1",
+				User:       "admin",
+				DeviceType: "cisco_ios",
+				Auth:       &Auth{Type: "password", PasswordFile: "passwords.dat"},
+				Enable:     &Enable{Value: "secret", PasswordFile: "passwords.dat"},
+			},
+			errorMsg: "'value' and 'password_file' are mutually exclusive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Version:  "1.0",
+				Profiles: map[string]*Profile{"device": tt.profile},
+				Routes: map[string]*Route{
+					"test-route": {Steps: []*RouteStep{{Profile: "device"}}},
+				},
+			}
+			cfg.SetDefaults()
+
+			err := Validate(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateEnable_DefaultPasswordFile(t *testing.T) {
+	enable := &Enable{}
+	require.NoError(t, validateEnable(enable))
+	assert.Equal(t, "passwords.dat", enable.PasswordFile)
+}
+
+func TestValidate_RetryOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *Options
+		errorMsg string
+	}{
+		{name: "retry settings", options: &Options{Retry: 3, RetryInterval: 5, RetryBackoff: 2}},
+		{name: "negative retry", options: &Options{Retry: -1}, errorMsg: "retry must be 0 or greater"},
+		{name: "negative retry_interval", options: &Options{Retry: 1, RetryInterval: -1}, errorMsg: "retry_interval must be 0 or greater"},
+		{name: "negative retry_backoff", options: &Options{Retry: 1, RetryBackoff: -2}, errorMsg: "retry_backoff must be 0 or greater"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Version: "1.0",
+				Profiles: map[string]*Profile{
+					"server": {
+						Host:         "server.example.com",
+						User:         "user1",
+						PromptMarker: "$ ",
+						Auth:         &Auth{Type: "password", PasswordFile: "passwords.dat"},
+					},
+				},
+				Routes: map[string]*Route{
+					"test-route": {Steps: []*RouteStep{{Profile: "server"}}},
+				},
+				Options: tt.options,
+			}
+
+			err := Validate(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidate_LogOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *Options
+		errorMsg string
+	}{
+		{name: "log with placeholders", options: &Options{Log: true, LogFile: "logs/{route}_{date}_{time}.log"}},
+		{name: "log without log_file", options: &Options{Log: true}},
+		{name: "log_file without log", options: &Options{LogFile: "ttlx.log"}, errorMsg: "log_file requires log: true"},
+		{name: "unknown placeholder", options: &Options{Log: true, LogFile: "{host}.log"}, errorMsg: "unknown placeholder {host}"},
+		{name: "single quote", options: &Options{Log: true, LogFile: "it's.log"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Version: "1.0",
+				Profiles: map[string]*Profile{
+					"server": {
+						Host:         "server.example.com",
+						User:         "user1",
+						PromptMarker: "$ ",
+						Auth:         &Auth{Type: "password", PasswordFile: "passwords.dat"},
+					},
+				},
+				Routes: map[string]*Route{
+					"test-route": {Steps: []*RouteStep{{Profile: "server"}}},
+				},
+				Options: tt.options,
+			}
+
+			err := Validate(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidate_NetworkDeviceHops(t *testing.T) {
+	tests := []struct {
+		name     string
+		route    []string
+		errorMsg string
+	}{
+		{name: "network device on the last step", route: []string{"bastion", "router"}},
+		{name: "network device as the only step", route: []string{"router"}},
+		{
+			name:     "network device on an intermediate step",
+			route:    []string{"bastion", "router", "server"},
+			errorMsg: "route 'main': profile 'router': device_type 'cisco_ios' is only supported on the last step (step 2 connects to the next step with ssh)",
+		},
+		{
+			name:     "network device on the first step",
+			route:    []string{"router", "server"},
+			errorMsg: "profile 'router': device_type 'cisco_ios' is only supported on the last step (step 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := make([]*RouteStep, 0, len(tt.route))
+			for _, profile := range tt.route {
+				steps = append(steps, &RouteStep{Profile: profile})
+			}
+			cfg := &Config{
+				Version: "1.0",
+				Profiles: map[string]*Profile{
+					"bastion": {Host: "bastion.example.com", User: "user", PromptMarker: "$ ", Auth: &Auth{Type: "password"}},
+					"router":  {Host: "10.0.0.1", User: "netadmin", DeviceType: "cisco_ios", Auth: &Auth{Type: "password", PasswordPrompt: "Password:"}},
+					"server":  {Host: "10.0.0.2", User: "user", PromptMarker: "$ ", Auth: &Auth{Type: "password", PasswordPrompt: "password:"}},
+				},
+				Routes: map[string]*Route{"main": {Steps: steps}},
+			}
+			cfg.SetDefaults()
+
+			err := Validate(cfg)
+			if tt.errorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidate_Commands(t *testing.T) {
+	checkExit := true
+	tests := []struct {
+		name       string
+		deviceType string
+		step       *RouteStep
+		errorMsg   string
+	}{
+		{
+			name: "check_exit on linux",
+			step: &RouteStep{Profile: "server", Commands: []*Command{{Run: "uptime", CheckExit: &checkExit}}},
+		},
+		{
+			name:     "empty command",
+			step:     &RouteStep{Profile: "server", Commands: []*Command{{Run: ""}}},
+			errorMsg: "step 1: command 1: run is required",
+		},
+		{
+			name:       "check_exit on network device",
+			deviceType: "junos",
+			step:       &RouteStep{Profile: "server", CheckExit: true, Commands: []*Command{{Run: "show version"}}},
+			errorMsg:   "check_exit is not supported for device_type 'junos'",
+		},
+		{
+			name: "foreach over items",
+			step: &RouteStep{Profile: "server", Commands: []*Command{{Foreach: &Foreach{
+				Items: []string{"nginx", "app"}, As: "service", Commands: []*Command{{Run: "systemctl restart ${service}"}},
+			}}}},
+		},
+		{
+			name: "foreach with run",
+			step: &RouteStep{Profile: "server", Commands: []*Command{{Run: "ls", Foreach: &Foreach{
+				Items: []string{"a"}, Commands: []*Command{{Run: "echo ${item}"}},
+			}}}},
+			errorMsg: "step 1: command 1: foreach: cannot be combined with other command settings",
+		},
+		{
+			name: "foreach with both items and from",
+			step: &RouteStep{Profile: "server", Commands: []*Command{{Foreach: &Foreach{
+				Items: []string{"a"}, From: "list", Commands: []*Command{{Run: "echo ${item}"}},
+			}}}},
+			errorMsg: "exactly one of 'items' and 'from' must be set",
+		},
+		{
+			name:     "foreach without commands",
+			step:     &RouteStep{Profile: "server", Commands: []*Command{{Foreach: &Foreach{Items: []string{"a"}}}}},
+			errorMsg: "commands must have at least one command",
+		},
+		{
+			name: "foreach with invalid as",
+			step: &RouteStep{Profile: "server", Commands: []*Command{{Foreach: &Foreach{
+				Items: []string{"a"}, As: "my-item", Commands: []*Command{{Run: "echo"}},
+			}}}},
+			errorMsg: "as 'my-item' must start with a letter",
+		},
+		{
+			name: "foreach with single quote in items",
+			step: &RouteStep{Profile: "server", Commands: []*Command{{Foreach: &Foreach{
+				Items: []string{"it's"}, Commands: []*Command{{Run: "echo ${item}"}},
+			}}}},
+		},
+		{
+			name: "invalid command in foreach",
+			step: &RouteStep{Profile: "server", Commands: []*Command{{Foreach: &Foreach{
+				Items: []string{"a"}, Commands: []*Command{{Run: "echo"}, {Run: ""}},
+			}}}},
+			errorMsg: "step 1: command 1: foreach: command 2: run is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Version: "1.0",
+				Profiles: map[string]*Profile{
+					"server": {
+						Host:         "server.example.com",
+						User:         "user1",
+						PromptMarker: "$ ",
+						DeviceType:   tt.deviceType,
+						Auth:         &Auth{Type: "password", PasswordFile: "passwords.dat"},
+					},
+				},
+				Routes: map[string]*Route{
+					"test-route": {Steps: []*RouteStep{tt.step}},
+				},
+			}
+
+			err := Validate(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateCapture(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      *Command
+		errorMsg string
+	}{
+		{name: "capture with save_as", cmd: &Command{Run: "cat BUILD", CaptureRegex: "build-([0-9]+)", SaveAs: "build_number"}},
+		{name: "capture with both expectations", cmd: &Command{Run: "cat STATUS", CaptureRegex: "status=.*", ExpectContains: "ok", ExpectNotContains: "failed"}},
+		{name: "expect_contains only", cmd: &Command{Run: "cat /etc/os-release", ExpectContains: "Rocky"}},
+		{name: "save_as without capture_regex", cmd: &Command{Run: "cat BUILD", SaveAs: "build"}, errorMsg: "save_as requires capture_regex"},
+		{name: "invalid save_as", cmd: &Command{Run: "cat BUILD", CaptureRegex: "[0-9]+", SaveAs: "1build"}, errorMsg: "save_as '1build' must start with a letter"},
+		{name: "invalid capture_regex", cmd: &Command{Run: "cat BUILD", CaptureRegex: "build-("}, errorMsg: "invalid capture_regex"},
+		{name: "both expectations without capture", cmd: &Command{Run: "cat log", ExpectContains: "ok", ExpectNotContains: "ng"}, errorMsg: "mutually exclusive"},
+		{name: "single quote in expectation", cmd: &Command{Run: "cat log", ExpectContains: "it's"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCapture(tt.cmd)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateFlowControl(t *testing.T) {
+	capture := &Command{Run: "cat MODE", CaptureRegex: "mode=([a-z]+)", SaveAs: "mode"}
+
+	tests := []struct {
+		name     string
+		route    []*RouteStep
+		errorMsg string
+	}{
+		{
+			name: "valid policies and conditions",
+			route: []*RouteStep{
+				{Profile: "a", Commands: []*Command{capture}},
+				{Profile: "b", OnFailure: "goto_step", GotoStep: 3, Commands: []*Command{
+					{Run: "restart", When: &Condition{Var: "mode", Equals: "full"}, OnFailure: "continue"},
+				}},
+				{Profile: "c", When: &Condition{Step: 2, Result: "failure"}},
+			},
+		},
+		{
+			name: "foreach over captured list",
+			route: []*RouteStep{{Profile: "a", Commands: []*Command{
+				{Run: "cat HOSTS", CaptureRegex: "hosts=(.+)", SaveAs: "hosts"},
+				{Foreach: &Foreach{From: "hosts", As: "host", Commands: []*Command{
+					{Run: "ping -c 1 ${host}", When: &Condition{Var: "host", NotEquals: "localhost"}, OnFailure: "continue"},
+				}}},
+			}}},
+		},
+		{
+			name: "foreach from uncaptured variable",
+			route: []*RouteStep{{Profile: "a", Commands: []*Command{
+				{Foreach: &Foreach{From: "hosts", Commands: []*Command{{Run: "ping ${item}"}}}},
+			}}},
+			errorMsg: "step 1: command 1: foreach: from 'hosts' is not captured by an earlier command",
+		},
+		{
+			name: "loop variable outside foreach",
+			route: []*RouteStep{{Profile: "a", Commands: []*Command{
+				{Foreach: &Foreach{Items: []string{"a"}, Commands: []*Command{{Run: "echo ${item}"}}}},
+				{Run: "echo", When: &Condition{Var: "item", Equals: "a"}},
+			}}},
+			errorMsg: "step 1: command 2: when: var 'item' is not captured by an earlier command",
+		},
+		{
+			name: "skip_remaining_commands inside foreach",
+			route: []*RouteStep{{Profile: "a", OnFailure: "skip_remaining_commands", Commands: []*Command{
+				{Foreach: &Foreach{Items: []string{"a"}, Commands: []*Command{{Run: "echo ${item}"}}}},
+			}}},
+			errorMsg: "step 1: command 1: foreach: command 1: on_failure 'skip_remaining_commands' is not allowed inside foreach",
+		},
+		{
+			name:     "invalid on_failure",
+			route:    []*RouteStep{{Profile: "a", OnFailure: "retry"}},
+			errorMsg: "step 1: invalid on_failure: retry",
+		},
+		{
+			name:     "goto_step backwards",
+			route:    []*RouteStep{{Profile: "a"}, {Profile: "b", OnFailure: "goto_step", GotoStep: 1}},
+			errorMsg: "step 2: goto_step must be a later step in the route",
+		},
+		{
+			name: "goto_step skipping a step",
+			route: []*RouteStep{
+				{Profile: "a", OnFailure: "goto_step", GotoStep: 3}, {Profile: "b"}, {Profile: "c"},
+			},
+			errorMsg: "step 1: goto_step must be the next step (2), got 3",
+		},
+		{
+			name: "when on an intermediate step",
+			route: []*RouteStep{
+				{Profile: "a"}, {Profile: "b", When: &Condition{Step: 1, Result: "success"}}, {Profile: "c"},
+			},
+			errorMsg: "step 2: when is only allowed on the last step (step 3 connects through this step)",
+		},
+		{
+			name:     "goto_step without policy",
+			route:    []*RouteStep{{Profile: "a", Commands: []*Command{{Run: "ls", GotoStep: 2}}}, {Profile: "b"}},
+			errorMsg: "step 1: command 1: goto_step requires on_failure: goto_step",
+		},
+		{
+			name:     "condition on uncaptured variable",
+			route:    []*RouteStep{{Profile: "a"}, {Profile: "b", When: &Condition{Var: "mode", Equals: "full"}}},
+			errorMsg: "var 'mode' is not captured by an earlier command",
+		},
+		{
+			name:     "condition on later step",
+			route:    []*RouteStep{{Profile: "a"}, {Profile: "b", When: &Condition{Step: 2, Result: "success"}}},
+			errorMsg: "step must refer to an earlier step",
+		},
+		{
+			name:     "condition with invalid result",
+			route:    []*RouteStep{{Profile: "a"}, {Profile: "b", When: &Condition{Step: 1, Result: "ok"}}},
+			errorMsg: "invalid result: ok",
+		},
+		{
+			name:     "condition with both var and step",
+			route:    []*RouteStep{{Profile: "a", Commands: []*Command{capture}}, {Profile: "b", When: &Condition{Var: "mode", Step: 1}}},
+			errorMsg: "exactly one of 'var' and 'step' must be set",
+		},
+		{
+			name:     "condition with multiple operators",
+			route:    []*RouteStep{{Profile: "a", Commands: []*Command{capture}}, {Profile: "b", When: &Condition{Var: "mode", Equals: "a", Contains: "b"}}},
+			errorMsg: "exactly one of 'equals', 'not_equals', and 'contains' must be set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFlowControl(tt.route)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateTransfer(t *testing.T) {
+	noWait := false
+	linux := &Profile{}
+	junos := &Profile{DeviceType: "junos"}
+
+	tests := []struct {
+		name     string
+		profile  *Profile
+		transfer *Transfer
+		stepNum  int
+		errorMsg string
+	}{
+		{name: "upload", profile: linux, transfer: &Transfer{Direction: "upload", Local: "app.conf", Remote: "/tmp/app.conf"}, stepNum: 1},
+		{name: "download", profile: linux, transfer: &Transfer{Direction: "download", Local: "app.log", Remote: "/var/log/app.log"}, stepNum: 1},
+		{name: "network device without wait", profile: junos, transfer: &Transfer{Direction: "upload", Local: "a", Remote: "b", Wait: &noWait}, stepNum: 1},
+		{name: "later step", profile: linux, transfer: &Transfer{Direction: "upload", Local: "a", Remote: "b"}, stepNum: 2, errorMsg: "scp is only available on the first step"},
+		{name: "zmodem on later step", profile: linux, transfer: &Transfer{Protocol: "zmodem", Direction: "upload", Local: "a", Remote: "/tmp/b", Checksum: true}, stepNum: 2},
+		{name: "kermit download", profile: linux, transfer: &Transfer{Protocol: "kermit", Direction: "download", Local: `logs\app.log`, Remote: "/var/log/app.log"}, stepNum: 2},
+		{name: "invalid protocol", profile: linux, transfer: &Transfer{Protocol: "ftp", Direction: "upload", Local: "a", Remote: "b"}, stepNum: 2, errorMsg: "invalid protocol: ftp"},
+		{name: "wait with zmodem", profile: linux, transfer: &Transfer{Protocol: "zmodem", Direction: "upload", Local: "a", Remote: "b", Wait: &noWait}, stepNum: 2, errorMsg: "wait is only supported for scp"},
+		{name: "zmodem download with different name", profile: linux, transfer: &Transfer{Protocol: "zmodem", Direction: "download", Local: "b.log", Remote: "/var/log/a.log"}, stepNum: 2, errorMsg: "local file name must be 'a.log'"},
+		{name: "checksum with xmodem", profile: linux, transfer: &Transfer{Protocol: "xmodem", Direction: "upload", Local: "a", Remote: "b", Checksum: true}, stepNum: 2, errorMsg: "checksum is not supported for xmodem"},
+		{name: "checksum with scp without wait", profile: linux, transfer: &Transfer{Direction: "upload", Local: "a", Remote: "b", Wait: &noWait, Checksum: true}, stepNum: 1, errorMsg: "checksum requires wait: true for scp"},
+		{name: "zmodem on network device", profile: junos, transfer: &Transfer{Protocol: "zmodem", Direction: "upload", Local: "a", Remote: "b"}, stepNum: 2, errorMsg: "protocol zmodem is not supported for device_type 'junos'"},
+		{name: "invalid direction", profile: linux, transfer: &Transfer{Direction: "push", Local: "a", Remote: "b"}, stepNum: 1, errorMsg: "invalid direction: push"},
+		{name: "missing local", profile: linux, transfer: &Transfer{Direction: "upload", Remote: "b"}, stepNum: 1, errorMsg: "local is required"},
+		{name: "missing remote", profile: linux, transfer: &Transfer{Direction: "upload", Local: "a"}, stepNum: 1, errorMsg: "remote is required"},
+		{name: "single quote in path", profile: linux, transfer: &Transfer{Direction: "upload", Local: "it's.txt", Remote: "b"}, stepNum: 1},
+		{name: "double quote in path", profile: linux, transfer: &Transfer{Direction: "upload", Local: "a", Remote: `/tmp/"b"`}, stepNum: 1, errorMsg: "local and remote cannot contain double quotes"},
+		{name: "wait on network device", profile: junos, transfer: &Transfer{Direction: "upload", Local: "a", Remote: "b"}, stepNum: 1, errorMsg: "wait is not supported for device_type 'junos'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTransfer(tt.profile, tt.transfer, tt.stepNum)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateForward(t *testing.T) {
+	tests := []struct {
+		name     string
+		forward  *Forward
+		errorMsg string
+	}{
+		{name: "local", forward: &Forward{Type: "local", BindPort: 8080, Host: "intranet", Port: 80}},
+		{name: "remote with bind address", forward: &Forward{Type: "remote", BindAddress: "127.0.0.1", BindPort: 9000, Host: "localhost", Port: 9000}},
+		{name: "dynamic", forward: &Forward{Type: "dynamic", BindPort: 1080}},
+		{name: "ipv6 destination", forward: &Forward{Type: "local", BindPort: 8080, Host: "[fd00::1]", Port: 80}},
+		{name: "invalid type", forward: &Forward{Type: "socks", BindPort: 1080}, errorMsg: "invalid type: socks"},
+		{name: "missing host", forward: &Forward{Type: "local", BindPort: 8080, Port: 80}, errorMsg: "local forwarding requires 'host'"},
+		{name: "invalid host", forward: &Forward{Type: "local", BindPort: 8080, Host: "a b", Port: 80}, errorMsg: "invalid host: a b"},
+		{name: "missing port", forward: &Forward{Type: "remote", BindPort: 9000, Host: "localhost"}, errorMsg: "port must be between 1 and 65535 (got 0)"},
+		{name: "bind_port out of range", forward: &Forward{Type: "dynamic", BindPort: 70000}, errorMsg: "bind_port must be between 1 and 65535 (got 70000)"},
+		{name: "dynamic with host", forward: &Forward{Type: "dynamic", BindPort: 1080, Host: "intranet"}, errorMsg: "dynamic forwarding does not take 'host' or 'port'"},
+		{name: "invalid bind_address", forward: &Forward{Type: "dynamic", BindAddress: "127.0.0.1'", BindPort: 1080}, errorMsg: "invalid bind_address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateForward(tt.forward)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateRouteForwards(t *testing.T) {
+	local := func(address string, port int) *Forward {
+		return &Forward{Type: "local", BindAddress: address, BindPort: port, Host: "intranet", Port: 80}
+	}
+	remote := func(port int) *Forward {
+		return &Forward{Type: "remote", BindPort: port, Host: "localhost", Port: 80}
+	}
+
+	tests := []struct {
+		name     string
+		first    []*Forward
+		second   []*Forward
+		errorMsg string
+	}{
+		{name: "no collision", first: []*Forward{local("", 8080)}, second: []*Forward{local("", 8081)}},
+		{name: "same port on different hosts", first: []*Forward{local("", 8080), remote(9000)}, second: []*Forward{local("", 8080)}},
+		{name: "different bind addresses", first: []*Forward{local("127.0.0.1", 8080), local("127.0.0.2", 8080)}},
+		{name: "dynamic on later step", second: []*Forward{{Type: "dynamic", BindPort: 1080}}},
+		{name: "local collision", first: []*Forward{local("", 8080), local("127.0.0.1", 8080)}, errorMsg: "step 1: forward 2: bind_port 8080 collides with a forwarding of step 1"},
+		// 1段目の remote と2段目の local はいずれも1段目のホストで待ち受ける
+		{name: "remote and next local collision", first: []*Forward{remote(9000)}, second: []*Forward{local("", 9000)}, errorMsg: "step 2: forward 1: bind_port 9000 collides with a forwarding of step 1"},
+		{name: "dynamic on first step", first: []*Forward{{Type: "dynamic", BindPort: 1080}}, errorMsg: "dynamic forwarding is not supported on the first step"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profiles := map[string]*Profile{
+				"bastion": {Forwards: tt.first},
+				"app":     {Forwards: tt.second},
+			}
+			route := &Route{Steps: []*RouteStep{{Profile: "bastion"}, {Profile: "app"}}}
+
+			err := validateRouteForwards(route, profiles)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateSSHOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *SSHOptions
+		errorMsg string
+	}{
+		{name: "all options", opts: &SSHOptions{IdentityFile: "~/.ssh/id_ed25519", ForwardAgent: true, Options: map[string]string{"StrictHostKeyChecking": "no"}, ExtraArgs: []string{"-C"}}},
+		{name: "option value with spaces", opts: &SSHOptions{Options: map[string]string{"ProxyCommand": "nc -x proxy:1080 %h %p"}}},
+		{name: "quote in identity_file", opts: &SSHOptions{IdentityFile: `~/.ssh/"id"`}, errorMsg: "identity_file cannot contain double quotes"},
+		{name: "invalid option name", opts: &SSHOptions{Options: map[string]string{"Strict-Host": "no"}}, errorMsg: "invalid option name: Strict-Host"},
+		{name: "empty option value", opts: &SSHOptions{Options: map[string]string{"Compression": ""}}, errorMsg: "option 'Compression' requires a value"},
+		{name: "single quote in option value", opts: &SSHOptions{Options: map[string]string{"User": "o'brien"}}},
+		{name: "double quote in option value", opts: &SSHOptions{Options: map[string]string{"User": `"o"`}}, errorMsg: "option 'User' cannot contain double quotes"},
+		{name: "empty extra arg", opts: &SSHOptions{ExtraArgs: []string{""}}, errorMsg: "extra_args cannot contain empty arguments"},
+		{name: "single quote in extra arg", opts: &SSHOptions{ExtraArgs: []string{"-o'ServerAliveInterval 30'"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSSHOptions(tt.opts)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidate_KeyfileOnLaterStep(t *testing.T) {
+	tests := []struct {
+		name     string
+		bastion  *SSHOptions
+		target   *SSHOptions
+		errorMsg string
+	}{
+		{name: "identity file on the previous host", target: &SSHOptions{IdentityFile: "~/.ssh/id_target"}},
+		{name: "agent forwarding to the previous host", bastion: &SSHOptions{ForwardAgent: true}},
+		{name: "no remote-side identity", errorMsg: "keyfile auth in route step 2 requires ssh_options.identity_file"},
+		{name: "agent forwarding on the target only", target: &SSHOptions{ForwardAgent: true}, errorMsg: "keyfile auth in route step 2 requires ssh_options.identity_file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Version: "1.0",
+				Profiles: map[string]*Profile{
+					"bastion": {
+						Host:         "bastion.example.com",
+						User:         "user1",
+						PromptMarker: "$ ",
+						Auth:         &Auth{Type: "password", PasswordFile: "passwords.dat"},
+						SSHOptions:   tt.bastion,
+					},
+					"target": {
+						Host:         "10.0.0.50",
+						User:         "user2",
+						PromptMarker: "$ ",
+						Auth:         &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa"},
+						SSHOptions:   tt.target,
+					},
+				},
+				Routes: map[string]*Route{
+					"test-route": {Steps: []*RouteStep{{Profile: "bastion"}, {Profile: "target"}}},
+				},
+			}
+
+			err := Validate(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateAuth_Passphrase(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     *Auth
+		errorMsg string
+	}{
+		{name: "passphrase_value", auth: &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa", PassphraseValue: "secret"}},
+		{name: "passphrase_file", auth: &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa", PassphraseFile: "passwords.dat"}},
+		{name: "passphrase prompt", auth: &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa", Passphrase: "prompt"}},
+		{
+			name:     "invalid passphrase",
+			auth:     &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa", Passphrase: "secret"},
+			errorMsg: "keyfile auth: invalid passphrase: secret (must be 'prompt')",
+		},
+		{
+			name:     "passphrase_value and passphrase_file",
+			auth:     &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa", PassphraseValue: "secret", PassphraseFile: "passwords.dat"},
+			errorMsg: "mutually exclusive",
+		},
+		{
+			name:     "passphrase_file and prompt",
+			auth:     &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa", PassphraseFile: "passwords.dat", Passphrase: "prompt"},
+			errorMsg: "mutually exclusive",
+		},
+		{
+			name:     "passphrase on password auth",
+			auth:     &Auth{Type: "password", PasswordFile: "passwords.dat", PassphraseValue: "secret"},
+			errorMsg: "password auth: passphrase settings require keyfile auth",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAuth(tt.auth)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateStrategy(t *testing.T) {
+	keyfile := func() *Profile {
+		return &Profile{Host: "10.0.0.10", User: "jump", PromptMarker: "$ ", Auth: &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa"}}
+	}
+	profiles := map[string]*Profile{
+		"bastion": {Host: "bastion", User: "user", PromptMarker: "$ ", Auth: &Auth{Type: "password", PasswordFile: "passwords.dat"}},
+		"jump":    keyfile(),
+		"target":  {Host: "10.0.0.20", User: "user", PromptMarker: "$ ", Auth: &Auth{Type: "password", PasswordFile: "passwords.dat", PasswordPrompt: "password:"}},
+		"password": {Host: "10.0.0.30", User: "user", PromptMarker: "$ ",
+			Auth: &Auth{Type: "password", PasswordFile: "passwords.dat", PasswordPrompt: "password:"}},
+		"passphrase": {Host: "10.0.0.40", User: "user", PromptMarker: "$ ",
+			Auth: &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa", Passphrase: "prompt"}},
+		"cisco": {Host: "10.0.0.50", User: "user", PromptMarker: "#", DeviceType: "cisco_ios",
+			Auth: &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa"}},
+		"options": {Host: "10.0.0.60", User: "user", PromptMarker: "$ ",
+			Auth: &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa"}, SSHOptions: &SSHOptions{ForwardAgent: true}},
+	}
+	steps := func(middle ...*RouteStep) []*RouteStep {
+		result := []*RouteStep{{Profile: "bastion"}}
+		result = append(result, middle...)
+		return append(result, &RouteStep{Profile: "target"})
+	}
+
+	tests := []struct {
+		name     string
+		route    *Route
+		errorMsg string
+	}{
+		{name: "nested (default)", route: &Route{Steps: steps(&RouteStep{Profile: "password"})}},
+		{name: "nested", route: &Route{Strategy: "nested", Steps: steps(&RouteStep{Profile: "password"})}},
+		{name: "proxyjump", route: &Route{Strategy: "proxyjump", Steps: steps(&RouteStep{Profile: "jump"}, &RouteStep{Profile: "jump"})}},
+		{
+			name:     "invalid strategy",
+			route:    &Route{Strategy: "direct", Steps: steps()},
+			errorMsg: "invalid strategy: direct (must be 'nested' or 'proxyjump')",
+		},
+		{
+			name:     "proxyjump without intermediate steps",
+			route:    &Route{Strategy: "proxyjump", Steps: steps()},
+			errorMsg: "strategy 'proxyjump' requires at least one intermediate step",
+		},
+		{
+			name:     "jump step with commands",
+			route:    &Route{Strategy: "proxyjump", Steps: steps(&RouteStep{Profile: "jump", Commands: []*Command{{Run: "ls"}}})},
+			errorMsg: "step 2: proxyjump: jump steps cannot have commands or transfer",
+		},
+		{
+			name:     "jump step with on_failure",
+			route:    &Route{Strategy: "proxyjump", Steps: steps(&RouteStep{Profile: "jump", OnFailure: "continue"})},
+			errorMsg: "jump steps cannot have when, on_failure, goto_step, or check_exit",
+		},
+		{
+			name:     "jump step with password auth",
+			route:    &Route{Strategy: "proxyjump", Steps: steps(&RouteStep{Profile: "password"})},
+			errorMsg: "profile 'password': jump hosts require keyfile auth without passphrase",
+		},
+		{
+			name:     "jump step with passphrase",
+			route:    &Route{Strategy: "proxyjump", Steps: steps(&RouteStep{Profile: "passphrase"})},
+			errorMsg: "jump hosts require keyfile auth without passphrase",
+		},
+		{
+			name:     "jump step on network device",
+			route:    &Route{Strategy: "proxyjump", Steps: steps(&RouteStep{Profile: "cisco"})},
+			errorMsg: "device_type 'cisco_ios' cannot be a jump host",
+		},
+		{
+			name:     "jump step with ssh_options",
+			route:    &Route{Strategy: "proxyjump", Steps: steps(&RouteStep{Profile: "options"})},
+			errorMsg: "forwards and ssh_options are not supported on jump hosts",
+		},
+		{
+			name: "goto_step to jump step",
+			route: &Route{Strategy: "proxyjump", Steps: []*RouteStep{
+				{Profile: "bastion"}, {Profile: "jump"}, {Profile: "target", OnFailure: "goto_step", GotoStep: 2},
+			}},
+			errorMsg: "step 3: cannot refer to proxyjump step",
+		},
+		{
+			name: "when on jump step result",
+			route: &Route{Strategy: "proxyjump", Steps: []*RouteStep{
+				{Profile: "bastion"}, {Profile: "jump"},
+				{Profile: "target", Commands: []*Command{{Run: "ls", When: &Condition{Step: 2, Result: "success"}}}},
+			}},
+			errorMsg: "step 3: command 'ls': cannot refer to proxyjump step",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStrategy(tt.route, profiles)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidate_ProxyJumpTargetKeyfile(t *testing.T) {
+	// proxyjump の最終ステップの鍵は1段目のホスト上で使用される
+	cfg, err := LoadConfig("../../test/fixtures/valid/proxyjump.yml")
+	require.NoError(t, err)
+	delete(cfg.Routes, "db-nested")
+	cfg.Profiles["db"].Auth = &Auth{Type: "keyfile", Path: "~/.ssh/id_rsa"}
+	cfg.Profiles["bastion"].SSHOptions = nil
+
+	err = Validate(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "route 'db-direct': profile 'db': keyfile auth in route step 4 requires ssh_options.identity_file")
+
+	// 1段目のエージェント転送で認証可能
+	cfg.Profiles["bastion"].SSHOptions = &SSHOptions{ForwardAgent: true}
+	assert.NoError(t, Validate(cfg))
+}
+
+func TestValidateCompletion(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      *Command
+		errorMsg string
+	}{
+		{name: "no timeout", cmd: &Command{Run: "uptime"}},
+		{name: "timeout seconds", cmd: &Command{Run: "backup.sh", Timeout: "3600"}},
+		{name: "timeout none", cmd: &Command{Run: "backup.sh", Timeout: "none"}},
+		{name: "completion_marker", cmd: &Command{Run: "yum -y update", CompletionMarker: "Complete!"}},
+		{
+			name:     "zero timeout",
+			cmd:      &Command{Run: "backup.sh", Timeout: "0"},
+			errorMsg: "invalid timeout: 0 (must be 'none' or a positive number of seconds)",
+		},
+		{
+			name:     "invalid timeout",
+			cmd:      &Command{Run: "backup.sh", Timeout: "forever"},
+			errorMsg: "invalid timeout: forever",
+		},
+		{
+			name:     "timeout with capture_regex",
+			cmd:      &Command{Run: "backup.sh", Timeout: "600", CaptureRegex: "[0-9]+"},
+			errorMsg: "timeout and completion_marker cannot be combined with capture_regex, expect_contains, or expect_not_contains",
+		},
+		{
+			name:     "completion_marker with expect_contains",
+			cmd:      &Command{Run: "backup.sh", CompletionMarker: "done", ExpectContains: "ok"},
+			errorMsg: "cannot be combined with capture_regex",
+		},
+		{
+			name: "completion_marker with single quote",
+			cmd:  &Command{Run: "backup.sh", CompletionMarker: "it's done"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCompletion(tt.cmd)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateKeepalive(t *testing.T) {
+	tests := []struct {
+		name      string
+		keepalive *Keepalive
+		errorMsg  string
+	}{
+		{name: "default method", keepalive: &Keepalive{Interval: 60}},
+		{name: "nul", keepalive: &Keepalive{Interval: 60, Method: "nul"}},
+		{name: "heartbeat", keepalive: &Keepalive{Interval: 60, Method: "heartbeat"}},
+		{name: "missing interval", keepalive: &Keepalive{}, errorMsg: "interval must be greater than 0"},
+		{
+			name:      "invalid method",
+			keepalive: &Keepalive{Interval: 60, Method: "echo"},
+			errorMsg:  "invalid method: echo (must be 'nul' or 'heartbeat')",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKeepalive(tt.keepalive)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestIsValidProfileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		// Valid names
+		{"simple", "bastion", true},
+		{"with hyphen", "web-01", true},
+		{"with underscore", "db_primary", true},
+		{"with dot", "web.prod", true},
+		{"leading digit", "10-0-0-1", true},
+
+		// Invalid names
+		{"empty string", "", false},
+		{"leading hyphen", "-web", false},
+		{"leading dot", ".web", false},
+		{"with space", "web server", false},
+		{"with quote", "o'brien", false},
+		{"with percent", "web%d", false},
+		{"with colon", "web:22", false},
+		{"multibyte", "踏み台", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsValidProfileName(tt.input), "IsValidProfileName(%q)", tt.input)
 		})
 	}
 }
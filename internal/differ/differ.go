@@ -0,0 +1,158 @@
+// Package differ compares generated TTL scripts with previously generated ones.
+package differ
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// volatileHeaderPrefixes are header lines that change without the configuration changing.
+var volatileHeaderPrefixes = []string{
+	"; Generated at: ",
+	"; Source SHA-256: ",
+}
+
+// sourceHeaderPrefix starts the header line naming the config file a script was generated from.
+const sourceHeaderPrefix = "; Source: "
+
+// Status describes how a route changed.
+type Status string
+
+// Route statuses.
+const (
+	Unchanged Status = "unchanged"
+	Modified  Status = "modified"
+	Added     Status = "added"
+	Removed   Status = "removed"
+)
+
+// RouteDiff is the comparison result of a single route.
+type RouteDiff struct {
+	Route  string
+	Status Status
+	// Diff is the unified diff of the route script (empty when unchanged).
+	Diff string
+}
+
+// Compare compares the old and new scripts of each route and returns the
+// results sorted by route name. oldLabel and newLabel name the two sides in
+// the diff headers.
+func Compare(oldTTLs, newTTLs map[string]string, oldLabel, newLabel string) ([]RouteDiff, error) {
+	names := make([]string, 0, len(oldTTLs)+len(newTTLs))
+	for name := range oldTTLs {
+		names = append(names, name)
+	}
+	for name := range newTTLs {
+		if _, ok := oldTTLs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	results := make([]RouteDiff, 0, len(names))
+	for _, name := range names {
+		oldTTL, inOld := oldTTLs[name]
+		newTTL, inNew := newTTLs[name]
+
+		status := Modified
+		switch {
+		case !inOld:
+			status = Added
+		case !inNew:
+			status = Removed
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(Normalize(oldTTL)),
+			B:        splitLines(Normalize(newTTL)),
+			FromFile: fmt.Sprintf("%s.ttl (%s)", name, oldLabel),
+			ToFile:   fmt.Sprintf("%s.ttl (%s)", name, newLabel),
+			Context:  contextLines,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if diff == "" {
+			status = Unchanged
+		}
+
+		results = append(results, RouteDiff{Route: name, Status: status, Diff: diff})
+	}
+	return results, nil
+}
+
+// Normalize removes header lines that differ between builds of the same
+// configuration, such as the generation timestamp.
+func Normalize(ttl string) string {
+	lines := strings.SplitAfter(ttl, "\n")
+	var sb strings.Builder
+	inHeader := true
+	for _, line := range lines {
+		// ヘッダーは最初の空行まで
+		if strings.TrimRight(line, "\r\n") == "" {
+			inHeader = false
+		}
+		if inHeader && hasVolatilePrefix(line) {
+			continue
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// FromSource reports whether ttl was generated from the config file named sourceFile,
+// according to the "; Source:" line of its header.
+func FromSource(ttl, sourceFile string) bool {
+	for _, line := range strings.Split(ttl, "\n") {
+		line = strings.TrimRight(line, "\r")
+		// ヘッダーは最初の空行まで
+		if line == "" {
+			return false
+		}
+		if strings.HasPrefix(line, sourceHeaderPrefix) {
+			return strings.TrimPrefix(line, sourceHeaderPrefix) == sourceFile
+		}
+	}
+	return false
+}
+
+// HasChanges reports whether any route differs.
+func HasChanges(results []RouteDiff) bool {
+	for _, r := range results {
+		if r.Status != Unchanged {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVolatilePrefix(line string) bool {
+	for _, prefix := range volatileHeaderPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLines splits text into lines that keep their line endings, as difflib expects.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	// 末尾に改行がない行は diff 出力が崩れないよう補う
+	if last := lines[len(lines)-1]; !strings.HasSuffix(last, "\n") {
+		lines[len(lines)-1] = last + "\n"
+	}
+	return lines
+}
@@ -0,0 +1,101 @@
+package differ
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const header = "; ========================================\n" +
+	"; Generated by ttlx 0.1.0-beta\n" +
+	"; Source: config.yml\n"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "timestamp",
+			input:    header + "; Route: main\n; Generated at: 2025-01-01 00:00:00\n\nsendln 'ls'\n",
+			expected: header + "; Route: main\n\nsendln 'ls'\n",
+		},
+		{
+			name:     "source hash",
+			input:    header + "; Source SHA-256: abcd\n; Route: main\n\nsendln 'ls'\n",
+			expected: header + "; Route: main\n\nsendln 'ls'\n",
+		},
+		{
+			name:     "comment after header kept",
+			input:    header + "\n; Generated at: step comment\n",
+			expected: header + "\n; Generated at: step comment\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Normalize(tt.input))
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	oldTTLs := map[string]string{
+		"same":    header + "; Generated at: 2025-01-01 00:00:00\n\nsendln 'ls'\n",
+		"changed": header + "\nsendln 'ls'\nsendln 'df'\n",
+		"gone":    header + "\nsendln 'ls'\n",
+	}
+	newTTLs := map[string]string{
+		"same":    header + "\nsendln 'ls'\n",
+		"changed": header + "\nsendln 'ls -l'\nsendln 'df'\n",
+		"new":     header + "\nsendln 'ls'\n",
+	}
+
+	results, err := Compare(oldTTLs, newTTLs, "out", "config.yml")
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	// ルート名順
+	assert.Equal(t, "changed", results[0].Route)
+	assert.Equal(t, Modified, results[0].Status)
+	assert.Contains(t, results[0].Diff, "--- changed.ttl (out)\n+++ changed.ttl (config.yml)\n")
+	assert.Contains(t, results[0].Diff, "-sendln 'ls'\n+sendln 'ls -l'\n sendln 'df'\n")
+
+	assert.Equal(t, "gone", results[1].Route)
+	assert.Equal(t, Removed, results[1].Status)
+	assert.Contains(t, results[1].Diff, "-sendln 'ls'\n")
+
+	assert.Equal(t, "new", results[2].Route)
+	assert.Equal(t, Added, results[2].Status)
+	assert.Contains(t, results[2].Diff, "+sendln 'ls'\n")
+
+	// タイムスタンプのみの違いは差分なし
+	assert.Equal(t, "same", results[3].Route)
+	assert.Equal(t, Unchanged, results[3].Status)
+	assert.Empty(t, results[3].Diff)
+
+	assert.True(t, HasChanges(results))
+	assert.False(t, HasChanges(results[3:]))
+}
+
+func TestCompare_MissingFinalNewline(t *testing.T) {
+	results, err := Compare(
+		map[string]string{"main": "sendln 'ls'"},
+		map[string]string{"main": "sendln 'ls'\n"},
+		"out", "config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, Unchanged, results[0].Status)
+}
+
+func TestFromSource(t *testing.T) {
+	ttl := header + "; Route: main\n\nsendln 'ls'\n"
+
+	assert.True(t, FromSource(ttl, "config.yml"))
+	assert.False(t, FromSource(ttl, "other.yml"))
+	assert.False(t, FromSource(ttl, "config"))
+
+	// 本体のコメントはヘッダーとして扱わない
+	assert.False(t, FromSource("; Route: main\n\n; Source: config.yml\n", "config.yml"))
+}
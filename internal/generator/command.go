@@ -0,0 +1,336 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+)
+
+// captureReferencePattern matches ${name} references to captured variables in commands.
+var captureReferencePattern = regexp.MustCompile(`\$\{([A-Za-z][A-Za-z0-9_]*)\}`)
+
+// generateStepCommands generates the commands of a route step,
+// including output capture, assertions, exit status checks, and flow control.
+// captured holds the save_as names captured so far in the route and is updated in place.
+// pager is the device's pager prompt (e.g. --More--), or "" when output is not paged.
+// flow reports whether the route uses `when` / `on_failure` flow control.
+// keepalive is the route's keepalive setting, or nil.
+func generateStepCommands(stepNum int, step *config.RouteStep, prompt, pager, labelID string, captured map[string]bool, flow bool, keepalive *config.Keepalive) ttlBlock {
+	return generateCommandList(fmt.Sprint(stepNum), stepNum, step, step.Commands, prompt, pager, labelID, captured, flow, keepalive)
+}
+
+// generateCommandList generates a list of commands. id is the label suffix of the list
+// ("<step>" for step commands, "<step>_<command>" for foreach bodies) and keeps
+// labels and markers unique across nested loops.
+func generateCommandList(id string, stepNum int, step *config.RouteStep, commands []*config.Command, prompt, pager, labelID string, captured map[string]bool, flow bool, keepalive *config.Keepalive) ttlBlock {
+	var b ttlBlock
+	for i, cmd := range commands {
+		cmdID := fmt.Sprintf("%s_%d", id, i+1)
+		policy, gotoStep := cmd.FailurePolicy(step)
+		nextLabel := "NEXT_" + cmdID
+
+		// foreach はループ本体を生成（失敗時の処理は本体の各コマンドで行う）
+		if cmd.Foreach != nil {
+			if flow && cmd.When != nil {
+				b = append(b, generateCondition(cmd.When, nextLabel)...)
+			}
+			b = append(b, generateForeach(cmdID, stepNum, step, cmd.Foreach, prompt, pager, labelID, captured, flow, keepalive)...)
+			if flow && cmd.When != nil {
+				b = append(b, labelStmt(nextLabel), blankStmt{})
+			}
+			continue
+		}
+
+		// 失敗時の遷移先（abort 以外はコマンドごとの失敗処理へ）
+		targets := abortTargets(labelID)
+		if flow && policy != "abort" {
+			failLabel := "FAIL_" + cmdID
+			targets = failureTargets{timeout: failLabel, command: failLabel, assert: failLabel}
+		}
+
+		// 実行条件
+		if flow && cmd.When != nil {
+			b = append(b, generateCondition(cmd.When, nextLabel)...)
+		}
+
+		b = append(b, generateCommand(cmd, cmdID, prompt, pager, targets, captured, keepalive)...)
+
+		if cmd.ChecksExit(step) {
+			// コマンド出力と区別するため、ステップ・コマンドごとに一意なマーカーを使用
+			marker := "TTLX_EXIT_" + cmdID
+			b = append(b,
+				commentStmt("Check exit status"),
+				call("sendln", strLit(`echo "`+marker+`:$?:"`)),
+				call("waitregex", strLit(marker+":([0-9]+):")),
+				gotoIf(resultIs("=", 0), targets.timeout),
+				call("str2int", varRef("exitcode"), varRef("groupmatchstr1")),
+				ifThen(binary(varRef("exitcode"), "<>", intLit(0)),
+					assign(varRef("failedcmd"), strLit(cmd.Run)),
+					gotoStmt(targets.command),
+				),
+			)
+			b = append(b, waitPrompt(prompt, targets.timeout)...)
+		}
+
+		// 失敗時の処理（ステップの結果を記録し、ポリシーに応じて遷移）
+		if flow && policy != "abort" {
+			b = append(b,
+				gotoStmt(nextLabel),
+				labelStmt(targets.timeout),
+				commentStmt("On failure: "+policy),
+				assign(stepResultVar(stepNum), intLit(2)),
+				gotoStmt(failureDestination(policy, gotoStep, stepNum, nextLabel)),
+			)
+		}
+		if flow && (cmd.When != nil || policy != "abort") {
+			b = append(b, labelStmt(nextLabel), blankStmt{})
+		}
+
+		if cmd.SaveAs != "" {
+			captured[cmd.SaveAs] = true
+		}
+	}
+	return b
+}
+
+// assertFailure records the assertion message and jumps to target.
+func assertFailure(message, target string) ttlBlock {
+	return ttlBlock{
+		assign(varRef("assertmsg"), strLit(message)),
+		gotoStmt(target),
+	}
+}
+
+func generateCommand(cmd *config.Command, cmdID, prompt, pager string, targets failureTargets, captured map[string]bool, keepalive *config.Keepalive) ttlBlock {
+	b := ttlBlock{commentStmt("Command: " + cmd.Run)}
+
+	// コマンド送信（取得済み変数を参照する場合は実行時に連結）
+	parts := splitCaptureReferences(cmd.Run, captured)
+	if len(parts) == 1 {
+		b = append(b, call("sendln", strLit(cmd.Run)))
+	} else {
+		b = append(b, concatStatements("cmdline", parts)...)
+		b = append(b, call("sendln", varRef("cmdline")))
+	}
+
+	switch {
+	case cmd.LongRunning():
+		b = append(b, generateCompletionWait(cmd, cmdID, prompt, targets.timeout, keepalive)...)
+	case cmd.CaptureRegex != "":
+		// 出力を取得し、取得値に対して検証
+		varName := varRef("captured")
+		if cmd.SaveAs != "" {
+			varName = varRef(captureVarName(cmd.SaveAs))
+		}
+		b = append(b,
+			commentStmt("Capture output"),
+			call("recvln"),
+			call("waitregex", strLit(cmd.CaptureRegex)),
+			gotoIf(resultIs("=", 0), targets.timeout),
+			assign(varName, varRef(captureMatchVar(cmd.CaptureRegex))),
+		)
+		b = append(b, waitPrompt(prompt, targets.timeout)...)
+		if cmd.ExpectContains != "" {
+			b = append(b,
+				call("strscan", varName, strLit(cmd.ExpectContains)),
+				ifThen(resultIs("=", 0), assertFailure(fmt.Sprintf("%s: expected captured value to contain \"%s\"", cmd.Run, cmd.ExpectContains), targets.assert)...),
+				blankStmt{},
+			)
+		}
+		if cmd.ExpectNotContains != "" {
+			b = append(b,
+				call("strscan", varName, strLit(cmd.ExpectNotContains)),
+				ifThen(resultIs(">", 0), assertFailure(fmt.Sprintf("%s: expected captured value not to contain \"%s\"", cmd.Run, cmd.ExpectNotContains), targets.assert)...),
+				blankStmt{},
+			)
+		}
+	case cmd.ExpectContains != "":
+		// 期待する文字列とプロンプトのどちらが先に出力されるかで判定
+		b = append(b, commentStmt("Expect output to contain: "+cmd.ExpectContains), call("recvln"))
+		b = append(b, waitFor(targets.timeout, strLit(cmd.ExpectContains), strLit(prompt))...)
+		b = append(b, ifThen(resultIs("=", 2), assertFailure(fmt.Sprintf("%s: expected output to contain \"%s\"", cmd.Run, cmd.ExpectContains), targets.assert)...))
+		b = append(b, waitPrompt(prompt, targets.timeout)...)
+	case cmd.ExpectNotContains != "":
+		b = append(b, commentStmt("Expect output not to contain: "+cmd.ExpectNotContains), call("recvln"))
+		b = append(b, waitFor(targets.timeout, strLit(cmd.ExpectNotContains), strLit(prompt))...)
+		b = append(b,
+			ifThen(resultIs("=", 1), assertFailure(fmt.Sprintf("%s: expected output not to contain \"%s\"", cmd.Run, cmd.ExpectNotContains), targets.assert)...),
+			blankStmt{},
+		)
+	default:
+		b = append(b, waitOutput(prompt, pager, cmdID, targets.timeout)...)
+	}
+
+	return b
+}
+
+// waitOutput waits for the prompt after a command. When the device pages its output
+// (pager is not empty), a space is sent at every pager prompt until the prompt appears.
+func waitOutput(prompt, pager, cmdID, timeoutLabel string) ttlBlock {
+	if pager == "" {
+		return waitPrompt(prompt, timeoutLabel)
+	}
+
+	moreLabel := "MORE_" + cmdID
+	b := ttlBlock{labelStmt(moreLabel)}
+	b = append(b, waitFor(timeoutLabel, strLit(prompt), strLit(pager))...)
+	return append(b,
+		ifThen(resultIs("=", 2),
+			call("send", strLit(" ")), // 次のページを表示
+			gotoStmt(moreLabel),
+		),
+		blankStmt{},
+	)
+}
+
+// generateCompletionWait generates the wait for a long-running command: the completion
+// marker (or the prompt) is awaited with the command's timeout, sending keepalive input
+// every interval when the route configures it, and then the prompt.
+func generateCompletionWait(cmd *config.Command, cmdID, prompt, timeoutLabel string, keepalive *config.Keepalive) ttlBlock {
+	var b ttlBlock
+
+	waitFor := prompt
+	if cmd.CompletionMarker != "" {
+		waitFor = cmd.CompletionMarker
+	}
+
+	// 待機中のみ timeout を変更し、完了・タイムアウトのいずれでも復元
+	setTimeout := func(seconds int) ttlBlock {
+		return ttlBlock{
+			commentStmt("Wait for completion (timeout: " + cmd.Timeout + ")"),
+			assign(varRef("savedtimeout"), varRef("timeout")),
+			assign(varRef("timeout"), intLit(seconds)),
+		}
+	}
+	restoreTimeout := assign(varRef("timeout"), varRef("savedtimeout"))
+
+	seconds, ok := cmd.TimeoutSeconds()
+	switch {
+	case !ok:
+		// timeout 未指定: options.timeout で完了マーカーを待機
+		b = append(b, waitPrompt(waitFor, timeoutLabel)...)
+	case keepalive.SendsInput() && (seconds == 0 || seconds > keepalive.Interval):
+		// interval ごとに待機を区切り、keepalive を送信して待機を継続
+		loopLabel := "KEEPALIVE_" + cmdID
+		var onTimeout ttlBlock
+		if seconds > 0 {
+			count := (seconds + keepalive.Interval - 1) / keepalive.Interval
+			onTimeout = ttlBlock{
+				assign(varRef("keepalivecount"), binary(varRef("keepalivecount"), "+", intLit(1))),
+				ifThen(binary(varRef("keepalivecount"), ">=", intLit(count)), restoreTimeout, gotoStmt(timeoutLabel)),
+			}
+		}
+		onTimeout = append(onTimeout, call("send", intLit(0)), gotoStmt(loopLabel))
+
+		b = append(b, setTimeout(keepalive.Interval)...)
+		b = append(b,
+			assign(varRef("keepalivecount"), intLit(0)),
+			labelStmt(loopLabel),
+			call("wait", strLit(waitFor)),
+			ifThen(resultIs("=", 0), onTimeout...),
+			restoreTimeout,
+			blankStmt{},
+		)
+	default:
+		b = append(b, setTimeout(seconds)...)
+		if keepalive != nil && !keepalive.SendsInput() {
+			b = append(b, commentStmt("Keepalive: Tera Term SSH heartbeat"))
+		}
+		b = append(b,
+			call("wait", strLit(waitFor)),
+			ifThen(resultIs("=", 0), restoreTimeout, gotoStmt(timeoutLabel)),
+			restoreTimeout,
+			blankStmt{},
+		)
+	}
+
+	// 完了マーカーの後はプロンプトを待機してから次のコマンドへ
+	if cmd.CompletionMarker != "" {
+		b = append(b, waitPrompt(prompt, timeoutLabel)...)
+	}
+
+	return b
+}
+
+// splitCaptureReferences splits a command into TTL string literals and
+// captured variables. ${name} is only substituted when name was captured
+// earlier in the route; anything else (e.g. shell variables) is kept as is.
+func splitCaptureReferences(command string, captured map[string]bool) []ttlExpr {
+	parts := make([]ttlExpr, 0)
+	literalStart := 0
+	for _, loc := range captureReferencePattern.FindAllStringSubmatchIndex(command, -1) {
+		name := command[loc[2]:loc[3]]
+		if !captured[name] {
+			continue
+		}
+		if loc[0] > literalStart {
+			parts = append(parts, strLit(command[literalStart:loc[0]]))
+		}
+		parts = append(parts, varRef(captureVarName(name)))
+		literalStart = loc[1]
+	}
+	if literalStart < len(command) || len(parts) == 0 {
+		parts = append(parts, strLit(command[literalStart:]))
+	}
+	return parts
+}
+
+// concatStatements generates TTL code that assigns the concatenation of parts to varName.
+func concatStatements(varName string, parts []ttlExpr) ttlBlock {
+	b := make(ttlBlock, 0, len(parts))
+	for i, part := range parts {
+		if i == 0 {
+			b = append(b, assign(varRef(varName), part))
+		} else {
+			b = append(b, call("strconcat", varRef(varName), part))
+		}
+	}
+	return b
+}
+
+// captureVarName returns the TTL variable name for a save_as name.
+// The prefix avoids collisions with variables used by the generated macro.
+func captureVarName(name string) string {
+	return "cap_" + name
+}
+
+// captureMatchVar returns the TTL system variable holding the captured value:
+// the first group when the regex has groups, otherwise the whole match.
+func captureMatchVar(captureRegex string) string {
+	if re, err := regexp.Compile(captureRegex); err == nil && re.NumSubexp() > 0 {
+		return "groupmatchstr1"
+	}
+	return "matchstr"
+}
+
+// stepChecksExit reports whether any command in the step checks its exit status
+// and reports a failure as an error (on_failure: abort).
+func stepChecksExit(step *config.RouteStep) bool {
+	for _, cmd := range step.AllCommands() {
+		if policy, _ := cmd.FailurePolicy(step); cmd.ChecksExit(step) && policy == "abort" {
+			return true
+		}
+	}
+	return false
+}
+
+// stepHasAssertions reports whether any command in the step has an output assertion
+// and reports a failure as an error (on_failure: abort).
+func stepHasAssertions(step *config.RouteStep) bool {
+	for _, cmd := range step.AllCommands() {
+		if policy, _ := cmd.FailurePolicy(step); cmd.HasAssertion() && policy == "abort" {
+			return true
+		}
+	}
+	return false
+}
+
+func generateCommands(commands []string, prompt, labelID string) ttlBlock {
+	var b ttlBlock
+	for _, cmd := range commands {
+		b = append(b, commentStmt("Command: "+cmd), call("sendln", strLit(cmd)))
+		b = append(b, waitPrompt(prompt, "TIMEOUT_"+labelID)...)
+	}
+	return b
+}
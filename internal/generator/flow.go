@@ -0,0 +1,122 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+)
+
+// stepResultValues maps a `when` step result to the value of stepresult<N>.
+// stepresult<N> is 0 until the step runs, 1 while it succeeds, and 2 once a command fails.
+var stepResultValues = map[string]int{
+	"skipped": 0,
+	"success": 1,
+	"failure": 2,
+}
+
+// failureTargets holds the labels a command jumps to when it fails.
+type failureTargets struct {
+	timeout string // 待機タイムアウト
+	command string // 終了コード確認の失敗
+	assert  string // 出力検証の失敗
+}
+
+// abortTargets returns the failure targets for on_failure: abort,
+// which report the error and clean up.
+func abortTargets(labelID string) failureTargets {
+	return failureTargets{
+		timeout: "TIMEOUT_" + labelID,
+		command: "ERROR_COMMAND_" + labelID,
+		assert:  "ERROR_ASSERT_" + labelID,
+	}
+}
+
+// routeUsesFlowControl reports whether any step or command in the route
+// uses `when` or a non-abort `on_failure` policy.
+func routeUsesFlowControl(route []*config.RouteStep) bool {
+	for _, step := range route {
+		if step.When != nil {
+			return true
+		}
+		for _, cmd := range step.AllCommands() {
+			if policy, _ := cmd.FailurePolicy(step); cmd.When != nil || policy != "abort" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// generateFlowVariables initializes step results and captured variables,
+// which may be referenced by `when` before they are set.
+func generateFlowVariables(route []*config.RouteStep) ttlBlock {
+	b := ttlBlock{commentStmt("=== Flow Control ===")}
+	for i := range route {
+		b = append(b, assign(stepResultVar(i+1), intLit(0)))
+	}
+	for _, step := range route {
+		for _, cmd := range step.AllCommands() {
+			if cmd.SaveAs != "" {
+				b = append(b, assign(varRef(captureVarName(cmd.SaveAs)), strLit("")))
+			}
+		}
+	}
+	return append(b, blankStmt{})
+}
+
+// generateCondition generates a `when` check that jumps to skipLabel when the condition is false.
+func generateCondition(cond *config.Condition, skipLabel string) ttlBlock {
+	if cond.Step > 0 {
+		return ttlBlock{
+			commentStmt(fmt.Sprintf("When: step %d %s", cond.Step, cond.Result)),
+			gotoIf(binary(stepResultVar(cond.Step), "<>", intLit(stepResultValues[cond.Result])), skipLabel),
+		}
+	}
+
+	varName := varRef(captureVarName(cond.Var))
+	switch {
+	case cond.Equals != "":
+		return ttlBlock{
+			commentStmt(fmt.Sprintf("When: %s equals '%s'", cond.Var, cond.Equals)),
+			call("strcompare", varName, strLit(cond.Equals)),
+			gotoIf(resultIs("<>", 0), skipLabel),
+		}
+	case cond.NotEquals != "":
+		return ttlBlock{
+			commentStmt(fmt.Sprintf("When: %s not equals '%s'", cond.Var, cond.NotEquals)),
+			call("strcompare", varName, strLit(cond.NotEquals)),
+			gotoIf(resultIs("=", 0), skipLabel),
+		}
+	default:
+		return ttlBlock{
+			commentStmt(fmt.Sprintf("When: %s contains '%s'", cond.Var, cond.Contains)),
+			call("strscan", varName, strLit(cond.Contains)),
+			gotoIf(resultIs("=", 0), skipLabel),
+		}
+	}
+}
+
+// failureDestination returns the label to continue at after a command fails under policy.
+func failureDestination(policy string, gotoStep, stepNum int, nextLabel string) string {
+	switch policy {
+	case "skip_remaining_commands":
+		return stepEndLabel(stepNum)
+	case "goto_step":
+		return stepLabel(gotoStep)
+	default: // continue
+		return nextLabel
+	}
+}
+
+// stepResultVar returns the variable holding the result of the step.
+func stepResultVar(stepNum int) varRef {
+	return varRef(fmt.Sprintf("stepresult%d", stepNum))
+}
+
+func stepLabel(stepNum int) string {
+	return fmt.Sprintf("STEP_%d", stepNum)
+}
+
+func stepEndLabel(stepNum int) string {
+	return fmt.Sprintf("STEP_%d_END", stepNum)
+}
@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+)
+
+// foreachUnrollLimit is the maximum number of static items that are unrolled
+// instead of generating a TTL loop.
+const foreachUnrollLimit = 3
+
+// generateForeach generates the foreach commands once per item,
+// with the current item assigned to the loop variable.
+// Short static lists are unrolled; longer ones are read from the array declared by
+// generateForeachVariables, and a captured list is split by the separator at runtime.
+func generateForeach(id string, stepNum int, step *config.RouteStep, loop *config.Foreach, prompt, pager, labelID string, captured map[string]bool, flow bool, keepalive *config.Keepalive) ttlBlock {
+	if foreachUnrolled(loop) {
+		return generateForeachUnrolled(id, stepNum, step, loop, prompt, pager, labelID, captured, flow, keepalive)
+	}
+
+	loopLabel := "FOREACH_" + id
+	endLabel := loopLabel + "_END"
+	itemVar := varRef(captureVarName(loop.Var()))
+
+	var b ttlBlock
+	if loop.From != "" {
+		rest := varRef("foreachrest_" + id)
+		pos := varRef("foreachpos_" + id)
+		length := varRef("foreachlen_" + id)
+		b = ttlBlock{
+			commentStmt(fmt.Sprintf("Foreach: %s in ${%s} (separator '%s')", loop.Var(), loop.From, loop.Sep())),
+			assign(rest, varRef(captureVarName(loop.From))),
+			labelStmt(loopLabel),
+			call("strlen", rest),
+			gotoIf(resultIs("=", 0), endLabel),
+			call("strscan", rest, strLit(loop.Sep())),
+			ifStmt{
+				cond: resultIs("=", 0),
+				// 区切り文字がなければ残り全体が最後の要素
+				then: ttlBlock{
+					assign(itemVar, rest),
+					assign(rest, strLit("")),
+				},
+				els: ttlBlock{
+					assign(pos, varRef("result")),
+					assign(length, binary(pos, "-", intLit(1))),
+					assign(itemVar, strLit("")),
+					ifThen(binary(length, ">", intLit(0)), call("strcopy", rest, intLit(1), length, itemVar)),
+					assign(length, binary(pos, "+", intLit(len(loop.Sep())-1))),
+					call("strremove", rest, intLit(1), length),
+				},
+			},
+			call("strtrim", itemVar, strLit(" ")),
+			// 空の要素は読み飛ばす
+			call("strlen", itemVar),
+			gotoIf(resultIs("=", 0), loopLabel),
+			blankStmt{},
+		}
+	} else {
+		index := varRef("foreachindex_" + id)
+		b = ttlBlock{
+			commentStmt(fmt.Sprintf("Foreach: %s in [%s]", loop.Var(), strings.Join(loop.Items, ", "))),
+			assign(index, intLit(0)),
+			labelStmt(loopLabel),
+			gotoIf(binary(index, ">=", intLit(len(loop.Items))), endLabel),
+			assign(itemVar, indexExpr{array: varRef("foreachitems_" + id), index: index}),
+			assign(index, binary(index, "+", intLit(1))),
+			blankStmt{},
+		}
+	}
+
+	// ループ変数はループ本体でのみ参照可能
+	body := make(map[string]bool, len(captured)+1)
+	for name := range captured {
+		body[name] = true
+	}
+	body[loop.Var()] = true
+	b = append(b, generateCommandList(id, stepNum, step, loop.Commands, prompt, pager, labelID, body, flow, keepalive)...)
+	for name := range body {
+		if name != loop.Var() {
+			captured[name] = true
+		}
+	}
+
+	return append(b, gotoStmt(loopLabel), labelStmt(endLabel), blankStmt{})
+}
+
+// generateForeachUnrolled generates the foreach commands for each static item in turn,
+// with ${var} in the commands replaced by the item.
+func generateForeachUnrolled(id string, stepNum int, step *config.RouteStep, loop *config.Foreach, prompt, pager, labelID string, captured map[string]bool, flow bool, keepalive *config.Keepalive) ttlBlock {
+	var b ttlBlock
+
+	for k, item := range loop.Items {
+		// when 条件で参照できるようループ変数にも代入
+		b = append(b,
+			commentStmt(fmt.Sprintf("Foreach: %s = %s", loop.Var(), item)),
+			assign(varRef(captureVarName(loop.Var())), strLit(item)),
+			blankStmt{},
+		)
+		commands := substituteLoopVar(loop.Commands, loop.Var(), item)
+		b = append(b, generateCommandList(fmt.Sprintf("%s_%d", id, k+1), stepNum, step, commands, prompt, pager, labelID, captured, flow, keepalive)...)
+	}
+
+	return b
+}
+
+// substituteLoopVar returns copies of commands with ${name} replaced by value,
+// including the commands of nested foreach loops.
+func substituteLoopVar(commands []*config.Command, name, value string) []*config.Command {
+	ref := "${" + name + "}"
+	result := make([]*config.Command, 0, len(commands))
+	for _, cmd := range commands {
+		c := *cmd
+		c.Run = strings.ReplaceAll(c.Run, ref, value)
+		c.ExpectContains = strings.ReplaceAll(c.ExpectContains, ref, value)
+		c.ExpectNotContains = strings.ReplaceAll(c.ExpectNotContains, ref, value)
+		if cmd.Foreach != nil {
+			loop := *cmd.Foreach
+			loop.Commands = substituteLoopVar(loop.Commands, name, value)
+			c.Foreach = &loop
+		}
+		result = append(result, &c)
+	}
+	return result
+}
+
+func foreachUnrolled(loop *config.Foreach) bool {
+	return len(loop.Items) > 0 && len(loop.Items) <= foreachUnrollLimit
+}
+
+// routeUsesStaticForeach reports whether any step in the route loops over
+// static items that are not unrolled.
+func routeUsesStaticForeach(route []*config.RouteStep) bool {
+	for _, step := range route {
+		for _, cmd := range step.AllCommands() {
+			if cmd.Foreach != nil && len(cmd.Foreach.Items) > 0 && !foreachUnrolled(cmd.Foreach) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// generateForeachVariables declares the item arrays of static foreach loops.
+// They are declared once at the top so that nested loops do not redeclare them.
+func generateForeachVariables(route []*config.RouteStep) ttlBlock {
+	b := ttlBlock{commentStmt("=== Foreach ===")}
+	for i, step := range route {
+		b = appendForeachArrays(b, fmt.Sprint(i+1), step.Commands)
+	}
+	return append(b, blankStmt{})
+}
+
+func appendForeachArrays(b ttlBlock, id string, commands []*config.Command) ttlBlock {
+	for i, cmd := range commands {
+		if cmd.Foreach == nil {
+			continue
+		}
+		cmdID := fmt.Sprintf("%s_%d", id, i+1)
+		if foreachUnrolled(cmd.Foreach) {
+			// 展開されたループ本体は反復ごとに識別子が異なる
+			for k, item := range cmd.Foreach.Items {
+				commands := substituteLoopVar(cmd.Foreach.Commands, cmd.Foreach.Var(), item)
+				b = appendForeachArrays(b, fmt.Sprintf("%s_%d", cmdID, k+1), commands)
+			}
+			continue
+		}
+		if items := cmd.Foreach.Items; len(items) > 0 {
+			array := varRef("foreachitems_" + cmdID)
+			b = append(b, call("strdim", array, intLit(len(items))))
+			for j, item := range items {
+				b = append(b, assign(indexExpr{array: array, index: intLit(j)}, strLit(item)))
+			}
+		}
+		b = appendForeachArrays(b, cmdID, cmd.Foreach.Commands)
+	}
+	return b
+}
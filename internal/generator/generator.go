@@ -2,7 +2,10 @@
 package generator
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -12,8 +15,24 @@ import (
 
 const version = "0.1.0-beta"
 
-// GenerateAll generates TTL scripts for all routes in the configuration.
+// Options controls the header of generated scripts.
+type Options struct {
+	// Timestamp is written to the "Generated at" header line.
+	// The zero value omits the line so that the output depends only on the configuration.
+	Timestamp time.Time
+
+	// SourceHash is the SHA-256 of the source configuration, written to the header when set.
+	SourceHash string
+}
+
+// GenerateAll generates TTL scripts for all routes in the configuration,
+// with the current time in the header.
 func GenerateAll(cfg *config.Config, sourceFile string) (map[string]string, error) {
+	return GenerateAllWithOptions(cfg, sourceFile, Options{Timestamp: time.Now()})
+}
+
+// GenerateAllWithOptions generates TTL scripts for all routes in the configuration.
+func GenerateAllWithOptions(cfg *config.Config, sourceFile string, opts Options) (map[string]string, error) {
 	result := make(map[string]string)
 
 	// Sort route names for deterministic processing
@@ -25,7 +44,7 @@ func GenerateAll(cfg *config.Config, sourceFile string) (map[string]string, erro
 
 	for _, routeName := range routeNames {
 		route := cfg.Routes[routeName]
-		ttl, err := generateRoute(cfg, routeName, route, sourceFile)
+		ttl, err := generateRoute(cfg, routeName, route, sourceFile, opts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate TTL for route '%s': %w", routeName, err)
 		}
@@ -36,42 +55,105 @@ func GenerateAll(cfg *config.Config, sourceFile string) (map[string]string, erro
 }
 
 // generateRoute generates a TTL script for a single route.
-func generateRoute(cfg *config.Config, routeName string, route []*config.RouteStep, sourceFile string) (string, error) {
-	var sb strings.Builder
+func generateRoute(cfg *config.Config, routeName string, route *config.Route, sourceFile string, opts Options) (string, error) {
+	steps := route.Steps
 
 	// ヘッダー生成
-	sb.WriteString(generateHeader(sourceFile, routeName))
+	b := generateHeader(sourceFile, routeName, route.Description, opts)
 
 	// 変数定義生成
-	sb.WriteString(generateVariables(cfg))
+	b = append(b, generateVariables(cfg)...)
+
+	// リトライ有効時は接続処理をリトライループで囲む
+	retry := retryEnabled(cfg)
+	logging := loggingEnabled(cfg)
+
+	// when / on_failure を使用する場合はステップの結果を記録
+	flow := routeUsesFlowControl(steps)
+	if flow {
+		b = append(b, generateFlowVariables(steps)...)
+	}
+
+	// 固定リストの foreach は配列を事前に宣言
+	if routeUsesStaticForeach(steps) {
+		b = append(b, generateForeachVariables(steps)...)
+	}
 
 	// ルートステップごとの処理生成
 	errorLabels := make([]string, 0)
-	for i, step := range route {
+	captured := make(map[string]bool) // 前段までに save_as で取得した変数名
+	for i, step := range steps {
+		// proxyjump の中間ステップは最終ステップの ssh -J に集約
+		if route.IsJumpStep(i) {
+			continue
+		}
+
 		profile := cfg.Profiles[step.Profile]
-		upperProfileName := strings.ToUpper(step.Profile)
+		labelID := stepLabelID(i+1, step.Profile) // 同じプロファイルを複数回経由してもラベルが重複しないようステップ番号を含める
+
+		// ステップ開始（実行条件の判定と結果の初期化）
+		if flow {
+			b = append(b, labelStmt(stepLabel(i+1)))
+			if step.When != nil {
+				b = append(b, generateCondition(step.When, stepEndLabel(i+1))...)
+			}
+			b = append(b, assign(stepResultVar(i+1), intLit(1)), blankStmt{})
+		}
 
 		if i == 0 {
 			// 最初のステップ: connect コマンド
 			// パスワード認証はすべて generateConnect() 内で処理される
-			sb.WriteString(generateConnect(i+1, step.Profile, upperProfileName, profile))
+			b = append(b, generateConnect(i+1, step.Profile, labelID, profile, retry)...)
+
+			// セッションログ開始（接続確立後）
+			if logging {
+				b = append(b, generateLogOpen(cfg, routeName)...)
+			}
 		} else {
 			// 2番目以降のステップ: ssh コマンド
-			sb.WriteString(generateSSH(i+1, step.Profile, upperProfileName, profile))
+			jumpHosts := ""
+			if route.ProxyJump() {
+				jumpHosts = generateJumpHosts(cfg, steps[1:i])
+			}
+			b = append(b, generateSSH(i+1, step.Profile, labelID, profile, jumpHosts, retry)...)
 
-			// パスワード認証処理
+			// パスワード認証・鍵のパスフレーズ入力処理
 			if profile.Auth.Type == "password" {
-				sb.WriteString(generatePasswordAuth(step.Profile, profile.Auth))
+				b = append(b, pauseLog(generatePasswordAuth(step.Profile, profile.Auth), logging)...)
+			} else if profile.Auth.HasPassphrase() {
+				b = append(b, pauseLog(generatePassphraseAuth(step.Profile, profile.Auth), logging)...)
+			}
+
+			// リトライ有効時はログイン完了までをリトライ対象とする
+			if retry {
+				b = append(b, waitPrompt(profile.LoginPrompt(), "RETRY_"+labelID)...)
 			}
 		}
 
+		// デバイス種別ごとの特権モード移行と初期化コマンド
+		b = append(b, generateDeviceSetup(i > 0 && !retry, step.Profile, labelID, profile, logging)...)
+
+		// ファイル転送（コマンド実行前）
+		if step.Transfer != nil {
+			// 2段目以降はログイン完了を待ってからリモートの送受信コマンドを起動
+			if i > 0 && !retry {
+				b = append(b, waitPrompt(profile.PromptMarker, "TIMEOUT_"+labelID)...)
+			}
+			b = append(b, generateTransfer(i+1, step.Transfer, profile.PromptMarker, labelID)...)
+		}
+
 		// コマンド実行
 		if len(step.Commands) > 0 {
-			sb.WriteString(generateCommands(step.Commands, profile.PromptMarker, upperProfileName))
+			b = append(b, generateStepCommands(i+1, step, profile.PromptMarker, profile.PagerPrompt(), labelID, captured, flow, route.Keepalive)...)
+		}
+
+		// ステップ終了（when 不成立・skip_remaining_commands の遷移先）
+		if flow {
+			b = append(b, labelStmt(stepEndLabel(i+1)), blankStmt{})
 		}
 
 		// エラーラベルを記録
-		errorLabels = append(errorLabels, upperProfileName)
+		errorLabels = append(errorLabels, labelID)
 	}
 
 	// 成功終了（auto_disconnect に基づいて処理を切り替え）
@@ -82,154 +164,695 @@ func generateRoute(cfg *config.Config, routeName string, route []*config.RouteSt
 
 	if autoDisconnect {
 		// 自動切断: 多段接続を順次exit、最後にclosett
-		sb.WriteString(generateAutoDisconnect(len(route)))
+		b = append(b, generateAutoDisconnect(cfg, route)...)
 	} else {
 		// 接続保持: セッションを維持したまま終了
-		sb.WriteString(successKeepAliveTemplate)
+		b = append(b,
+			commentStmt("=== Success (Keep connection alive) ==="),
+			labelStmt("SUCCESS"),
+			call("end"),
+			blankStmt{},
+		)
 	}
 
-	// エラーハンドリング生成
-	sb.WriteString(generateErrorHandling(errorLabels, route))
+	// エラーハンドリング生成（本体から遷移するラベルのみ）
+	b = append(b, generateErrorHandling(cfg, errorLabels, route.Hops(), b.gotoTargets())...)
 
-	return sb.String(), nil
+	return b.render()
 }
 
-func generateHeader(sourceFile, routeName string) string {
-	now := time.Now().Format("2006-01-02 15:04:05")
-	return fmt.Sprintf(headerTemplate, version, sourceFile, routeName, now)
+// labelNameLimit is the maximum length of a TTL label name.
+const labelNameLimit = 32
+
+// longestLabelPrefix is the longest prefix put in front of a step's label ID.
+const longestLabelPrefix = "ERROR_TRANSFER_"
+
+// labelUnsafePattern matches characters that cannot appear in a TTL label name.
+var labelUnsafePattern = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// stepLabelID returns the suffix of the labels of a route step, e.g. TIMEOUT_2_WEB_01.
+// It is made of the step number and the profile name in upper case with characters
+// other than letters, digits, and underscores replaced, and truncated so that every
+// label of the step fits in labelNameLimit.
+func stepLabelID(stepNum int, profileName string) string {
+	id := fmt.Sprintf("%d_", stepNum)
+	name := labelUnsafePattern.ReplaceAllString(strings.ToUpper(profileName), "_")
+	if limit := labelNameLimit - len(longestLabelPrefix) - len(id); len(name) > limit {
+		name = name[:limit]
+	}
+	return id + name
+}
+
+// headerRule is the separator line around the header comment.
+const headerRule = "========================================"
+
+func generateHeader(sourceFile, routeName, description string, opts Options) ttlBlock {
+	b := ttlBlock{
+		commentStmt(headerRule),
+		commentStmt("Generated by ttlx " + version),
+		commentStmt("Source: " + sourceFile),
+	}
+	if opts.SourceHash != "" {
+		b = append(b, commentStmt("Source SHA-256: "+opts.SourceHash))
+	}
+	b = append(b, commentStmt("Route: "+routeName))
+	if description = strings.TrimSpace(description); description != "" {
+		// 複数行の説明は2行目以降を字下げして続ける
+		lines := strings.Split(description, "\n")
+		b = append(b, commentStmt("Description: "+lines[0]))
+		for _, line := range lines[1:] {
+			b = append(b, commentStmt("  "+strings.TrimRight(line, " \t")))
+		}
+	}
+	if !opts.Timestamp.IsZero() {
+		b = append(b, commentStmt("Generated at: "+opts.Timestamp.Format("2006-01-02 15:04:05")))
+	}
+	return append(b, commentStmt(headerRule), blankStmt{})
+}
+
+// SourceHash returns the hex-encoded SHA-256 of a source configuration for Options.SourceHash.
+func SourceHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
-func generateVariables(cfg *config.Config) string {
+func generateVariables(cfg *config.Config) ttlBlock {
 	timeout := 30
 	if cfg.Options != nil && cfg.Options.Timeout > 0 {
 		timeout = cfg.Options.Timeout
 	}
-	return fmt.Sprintf(variablesTemplate, timeout)
+	b := ttlBlock{
+		commentStmt("=== Variables ==="),
+		assign(varRef("timeout"), intLit(timeout)),
+	}
+
+	if retryEnabled(cfg) {
+		interval := 5
+		if cfg.Options.RetryInterval > 0 {
+			interval = cfg.Options.RetryInterval
+		}
+		backoff := 1
+		if cfg.Options.RetryBackoff > 0 {
+			backoff = cfg.Options.RetryBackoff
+		}
+		b = append(b,
+			assign(varRef("retrymax"), intLit(cfg.Options.Retry)),
+			assign(varRef("retryattempts"), intLit(cfg.Options.Retry+1)),
+			assign(varRef("retryinterval"), intLit(interval)),
+			assign(varRef("retrybackoff"), intLit(backoff)),
+		)
+	}
+
+	return append(b, blankStmt{})
 }
 
-func generateConnect(stepNum int, profileName, upperProfileName string, profile *config.Profile) string {
+// loggingEnabled reports whether session logging is configured.
+func loggingEnabled(cfg *config.Config) bool {
+	return cfg.Options != nil && cfg.Options.Log
+}
+
+// generateLogOpen generates logopen with the log file name built at runtime.
+// {route} is resolved at generation time, {date} and {time} when the macro runs.
+func generateLogOpen(cfg *config.Config, routeName string) ttlBlock {
+	logFile := cfg.Options.LogFile
+	if logFile == "" {
+		logFile = "{route}_{date}_{time}.log"
+	}
+	logFile = strings.ReplaceAll(logFile, "{route}", routeName)
+
+	b := ttlBlock{commentStmt("=== Logging ===")}
+	if strings.Contains(logFile, "{date}") {
+		b = append(b, call("getdate", varRef("logdate"), strLit("%Y%m%d")))
+	}
+	if strings.Contains(logFile, "{time}") {
+		b = append(b, call("gettime", varRef("logtime"), strLit("%H%M%S")))
+	}
+
+	// リテラル部分とプレースホルダーを順に連結
+	parts := make([]ttlExpr, 0)
+	literalStart := 0
+	for _, loc := range logPlaceholderPattern.FindAllStringIndex(logFile, -1) {
+		if loc[0] > literalStart {
+			parts = append(parts, strLit(logFile[literalStart:loc[0]]))
+		}
+		parts = append(parts, varRef("log"+logFile[loc[0]+1:loc[1]-1])) // {date} -> logdate, {time} -> logtime
+		literalStart = loc[1]
+	}
+	if literalStart < len(logFile) {
+		parts = append(parts, strLit(logFile[literalStart:]))
+	}
+	b = append(b, concatStatements("logfile", parts)...)
+
+	return append(b,
+		call("logopen", varRef("logfile"), intLit(0), intLit(boolToInt(cfg.Options.LogAppend)), intLit(1), intLit(boolToInt(cfg.Options.LogTimestamp))),
+		blankStmt{},
+	)
+}
+
+// logPlaceholderPattern matches the runtime placeholders in a log file name.
+var logPlaceholderPattern = regexp.MustCompile(`\{(date|time)\}`)
+
+// pauseLog wraps password entry with logpause/logstart so secrets never reach the log.
+func pauseLog(code ttlBlock, logging bool) ttlBlock {
+	if !logging || len(code) == 0 {
+		return code
+	}
+	b := ttlBlock{commentStmt("Pause logging during password entry"), call("logpause")}
+	b = append(b, trimBlank(code)...)
+	return append(b, call("logstart"), blankStmt{})
+}
+
+// trimBlank returns b without its trailing empty line.
+func trimBlank(b ttlBlock) ttlBlock {
+	if len(b) > 0 {
+		if _, ok := b[len(b)-1].(blankStmt); ok {
+			return b[:len(b)-1]
+		}
+	}
+	return b
+}
+
+// logClose returns the logclose statement emitted before closett.
+func logClose(cfg *config.Config) ttlBlock {
+	if !loggingEnabled(cfg) {
+		return nil
+	}
+	return ttlBlock{call("logclose")}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// retryEnabled reports whether connection retries are configured.
+func retryEnabled(cfg *config.Config) bool {
+	return cfg.Options != nil && cfg.Options.Retry > 0
+}
+
+// retryInit initializes the attempt counter and the retry interval at the start of a step.
+func retryInit() ttlBlock {
+	return ttlBlock{
+		assign(varRef("attempt"), intLit(0)),
+		assign(varRef("retrywait"), varRef("retryinterval")),
+	}
+}
+
+// retryCount counts a connection attempt.
+func retryCount() ttlNode {
+	return assign(varRef("attempt"), binary(varRef("attempt"), "+", intLit(1)))
+}
+
+// waitFor waits for one of patterns and jumps to target when the wait times out.
+func waitFor(target string, patterns ...ttlExpr) ttlBlock {
+	return ttlBlock{
+		call("wait", patterns...),
+		gotoIf(resultIs("=", 0), target),
+	}
+}
+
+// waitPrompt waits for prompt and jumps to target when the wait times out.
+func waitPrompt(prompt, target string) ttlBlock {
+	return append(waitFor(target, strLit(prompt)), blankStmt{})
+}
+
+func generateConnect(stepNum int, profileName, labelID string, profile *config.Profile, retry bool) ttlBlock {
 	authType := profile.Auth.Type
-	keyfileOption := ""
+	connectOptions := ""
 	passwordOption := ""
 
-	// パスワードファイルから取得する場合は専用テンプレートを使用
-	if authType == "password" && profile.Auth.PasswordFile != "" {
-		return fmt.Sprintf(
-			passwordFileConnectTemplate,
-			stepNum,
-			profileName,
-			upperProfileName,
-			profile.Auth.PasswordFile,
-			profileName, // password name = profile name
-			profile.Host,
-			profile.Port,
-			authType,
-			profile.User,
-			keyfileOption,
-			upperProfileName,
-			profile.PromptMarker,
-			upperProfileName,
-		)
+	b := ttlBlock{commentStmt(fmt.Sprintf("=== Step %d: %s ===", stepNum, profileName))}
+
+	// リトライ有効時は失敗時の遷移先をリトライ処理に切り替え
+	connectErrorLabel := "ERROR_CONNECT_" + labelID
+	timeoutLabel := "TIMEOUT_" + labelID
+	if retry {
+		b = append(b, retryInit()...)
+		connectErrorLabel = "RETRY_CONNECT_" + labelID
+		timeoutLabel = "RETRY_" + labelID
+	}
+	b = append(b, labelStmt("CONNECT_"+labelID))
+	if retry {
+		b = append(b, retryCount())
 	}
 
 	if authType == "keyfile" {
-		keyfileOption = fmt.Sprintf(" /keyfile=%s", profile.Auth.Path)
-	} else if authType == "password" && profile.Auth.Value != "" {
-		// パスワードが直接指定されている場合は connect コマンドに含める
-		passwordOption = fmt.Sprintf(" /passwd=%s", profile.Auth.Value)
+		connectOptions = fmt.Sprintf(" /keyfile=%s", profile.Auth.Path)
+	}
+	connectOptions += generateAgentOption(profile) + generateForwardOptions(profile)
+
+	// パスワード・パスフレーズを実行時に取得する場合は connect コマンドに連結
+	secretName, fetch := "password", ttlBlock(nil)
+	switch {
+	case authType == "password" && profile.Auth.PasswordFile != "":
+		fetch = fetchPassword(profile.Auth.PasswordFile, profileName) // password name = profile name
+	case authType == "keyfile" && profile.Auth.PassphraseFile != "":
+		secretName = "passphrase"
+		fetch = fetchPassphraseFile(profile.Auth.PassphraseFile, profileName)
+	case authType == "keyfile" && profile.Auth.Passphrase == "prompt":
+		secretName = "passphrase"
+		fetch = promptPassphrase(profileName)
+	}
+	if fetch != nil {
+		// リトライ時に前回の接続コマンドへ追記しないよう初期化
+		if retry {
+			b = append(b, assign(varRef("connectcmd"), strLit("")))
+		}
+		b = append(b, fetch...)
+		b = append(b,
+			blankStmt{},
+			commentStmt("Build connect command with "+secretName),
+			call("strreplace", varRef(secretName), intLit(1), strLit(`"`), strLit(`""`)), // ダブルクォートを二重にしてコマンドラインの引用符内に埋め込む
+			call("strconcat", varRef("connectcmd"), strLit(fmt.Sprintf(`%s:%d /ssh /auth=%s /user=%s%s /passwd="`, profile.Host, profile.Port, authType, profile.User, connectOptions))),
+			call("strconcat", varRef("connectcmd"), varRef(secretName)),
+			call("strconcat", varRef("connectcmd"), strLit(`"`)),
+			call("connect", varRef("connectcmd")),
+		)
+	} else {
+		if authType == "password" && profile.Auth.Value != "" {
+			// パスワードが直接指定されている場合は connect コマンドに含める
+			passwordOption = " /passwd=" + ttsshQuote(profile.Auth.Value)
+		} else if authType == "keyfile" && profile.Auth.PassphraseValue != "" {
+			passwordOption = " /passwd=" + ttsshQuote(profile.Auth.PassphraseValue)
+		}
+		b = append(b, call("connect", strLit(fmt.Sprintf("%s:%d /ssh /auth=%s /user=%s%s%s", profile.Host, profile.Port, authType, profile.User, connectOptions, passwordOption))))
 	}
 
-	return fmt.Sprintf(
-		connectTemplate,
-		stepNum,
-		profileName,
-		upperProfileName,
-		profile.Host,
-		profile.Port,
-		authType,
-		profile.User,
-		keyfileOption,
-		passwordOption,
-		upperProfileName,
-		profile.PromptMarker,
-		upperProfileName,
-	)
+	b = append(b, gotoIf(resultIs("<>", 2), connectErrorLabel))
+	return append(b, waitPrompt(profile.LoginPrompt(), timeoutLabel)...)
 }
 
-func generateSSH(stepNum int, profileName, upperProfileName string, profile *config.Profile) string {
-	return fmt.Sprintf(
-		sshTemplate,
-		stepNum,
-		profileName,
+// ttsshQuote quotes an option value for the Tera Term command line, which splits
+// options at spaces: the value is wrapped in double quotes and double quotes in it
+// are doubled, so that spaces or a leading / are not read as separate options.
+func ttsshQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+// generateSSH generates the ssh command of a later step.
+// jumpHosts is the -J list of a proxyjump route, or "" to connect directly.
+func generateSSH(stepNum int, profileName, labelID string, profile *config.Profile, jumpHosts string, retry bool) ttlBlock {
+	b := ttlBlock{commentStmt(fmt.Sprintf("=== Step %d: %s ===", stepNum, profileName))}
+
+	// リトライ有効時はssh実行前にリトライ用ラベルを設置
+	timeoutLabel := "TIMEOUT_" + labelID
+	if retry {
+		b = append(b, retryInit()...)
+		b = append(b, labelStmt("SSH_"+labelID), retryCount())
+		timeoutLabel = "RETRY_" + labelID
+	}
+
+	b = append(b, call("sendln", strLit(fmt.Sprintf(
+		"ssh%s %s@%s -p %d%s",
+		jumpArg(jumpHosts)+generateSSHOptionArgs(profile.SSHOptions),
 		profile.User,
 		profile.Host,
 		profile.Port,
-		profile.Auth.PasswordPrompt,
-		upperProfileName,
-	)
+		generateForwardArgs(profile),
+	))))
+
+	// パスフレーズのない鍵認証はパスワード入力がないため、待機せずに後続の処理へ進む
+	// （リトライ有効時は直後にログイン完了を待機）
+	if profile.Auth.Type == "keyfile" {
+		if profile.Auth.HasPassphrase() {
+			return append(b, waitPrompt(config.PassphrasePrompt, timeoutLabel)...)
+		}
+		if retry {
+			return b
+		}
+		return append(b, blankStmt{})
+	}
+	return append(b, waitPrompt(profile.Auth.PasswordPrompt, timeoutLabel)...)
 }
 
-func generatePasswordAuth(profileName string, auth *config.Auth) string {
-	// password_fileが設定されている場合（デフォルト値含む）
-	if auth.PasswordFile != "" {
-		return fmt.Sprintf(passwordFileTemplate, auth.PasswordFile, profileName)
+// generateJumpHosts generates the ssh -J list ("user@host:port,...") of the intermediate steps.
+func generateJumpHosts(cfg *config.Config, jumps []*config.RouteStep) string {
+	hosts := make([]string, 0, len(jumps))
+	for _, step := range jumps {
+		profile := cfg.Profiles[step.Profile]
+		host := profile.Host
+		// IPv6 アドレスはポート番号と区別するため角括弧で囲む
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		hosts = append(hosts, fmt.Sprintf("%s@%s:%d", profile.User, host, profile.Port))
 	}
+	return strings.Join(hosts, ",")
+}
 
-	// 直接パスワード指定
-	if auth.Value != "" {
-		return fmt.Sprintf(passwordValueTemplate, auth.Value)
+// jumpArg returns the -J argument of the ssh command for jumpHosts.
+func jumpArg(jumpHosts string) string {
+	if jumpHosts == "" {
+		return ""
 	}
+	return " -J " + jumpHosts
+}
 
+// generateAgentOption generates the Tera Term connect option for agent forwarding (/ssh-A).
+// The other ssh_options only apply to the ssh command on later steps.
+func generateAgentOption(profile *config.Profile) string {
+	if profile.SSHOptions != nil && profile.SSHOptions.ForwardAgent {
+		return " /ssh-A"
+	}
 	return ""
 }
 
-func generateCommands(commands []string, prompt, upperProfileName string) string {
+// generateForwardOptions generates Tera Term connect options (/ssh-L, /ssh-R)
+// for the profile's port forwardings on the first step.
+func generateForwardOptions(profile *config.Profile) string {
+	var sb strings.Builder
+	for _, forward := range profile.Forwards {
+		if forward.Type == "remote" {
+			sb.WriteString(" /ssh-R" + forward.Spec())
+		} else {
+			sb.WriteString(" /ssh-L" + forward.Spec())
+		}
+	}
+	return sb.String()
+}
+
+// generateSSHOptionArgs generates ssh arguments (-i, -A, -o, extra args) for later steps.
+// -o options are sorted by name so that the output is deterministic.
+func generateSSHOptionArgs(opts *config.SSHOptions) string {
+	if opts == nil {
+		return ""
+	}
+
 	var sb strings.Builder
-	for _, cmd := range commands {
-		sb.WriteString(fmt.Sprintf(commandTemplate, cmd, cmd, prompt, upperProfileName))
+	if opts.IdentityFile != "" {
+		sb.WriteString(fmt.Sprintf(` -i "%s"`, opts.IdentityFile))
+	}
+	if opts.ForwardAgent {
+		sb.WriteString(" -A")
+	}
+
+	keys := make([]string, 0, len(opts.Options))
+	for key := range opts.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		option := key + "=" + opts.Options[key]
+		// 空白を含む値（ProxyCommand など）は1つの引数になるようクォート
+		if strings.ContainsAny(option, " \t") {
+			option = `"` + option + `"`
+		}
+		sb.WriteString(" -o " + option)
+	}
+
+	for _, arg := range opts.ExtraArgs {
+		sb.WriteString(" " + arg)
 	}
 	return sb.String()
 }
 
-func generateErrorHandling(errorLabels []string, route []*config.RouteStep) string {
+// generateForwardArgs generates ssh arguments (-L, -R, -D)
+// for the profile's port forwardings on later steps.
+func generateForwardArgs(profile *config.Profile) string {
+	flags := map[string]string{"local": "-L", "remote": "-R", "dynamic": "-D"}
+
 	var sb strings.Builder
+	for _, forward := range profile.Forwards {
+		sb.WriteString(fmt.Sprintf(" %s %s", flags[forward.Type], forward.Spec()))
+	}
+	return sb.String()
+}
+
+// fetchPassword reads the password from the password file.
+func fetchPassword(passwordFile, passwordName string) ttlBlock {
+	return ttlBlock{
+		commentStmt("Password authentication (from password file)"),
+		call("getpassword", strLit(passwordFile), strLit(passwordName), varRef("password")),
+	}
+}
+
+// fetchPassphraseFile reads the key passphrase from the password file.
+func fetchPassphraseFile(passphraseFile, passwordName string) ttlBlock {
+	return ttlBlock{
+		commentStmt("Key passphrase (from password file)"),
+		call("getpassword", strLit(passphraseFile), strLit(passwordName), varRef("passphrase")),
+	}
+}
+
+// promptPassphrase asks for the key passphrase in a dialog.
+func promptPassphrase(profileName string) ttlBlock {
+	return ttlBlock{
+		commentStmt("Key passphrase (prompt)"),
+		call("passwordbox", strLit("Enter passphrase for "+profileName), strLit("Key passphrase")),
+		assign(varRef("passphrase"), varRef("inputstr")),
+	}
+}
+
+func generatePassphraseAuth(profileName string, auth *config.Auth) ttlBlock {
+	switch {
+	case auth.PassphraseFile != "":
+		return append(fetchPassphraseFile(auth.PassphraseFile, profileName), call("sendln", varRef("passphrase")), blankStmt{})
+	case auth.Passphrase == "prompt":
+		return append(promptPassphrase(profileName), call("sendln", varRef("passphrase")), blankStmt{})
+	default:
+		return ttlBlock{commentStmt("Key passphrase"), call("sendln", strLit(auth.PassphraseValue)), blankStmt{}}
+	}
+}
+
+func generatePasswordAuth(profileName string, auth *config.Auth) ttlBlock {
+	// password_fileが設定されている場合（デフォルト値含む）
+	if auth.PasswordFile != "" {
+		return append(fetchPassword(auth.PasswordFile, profileName), call("sendln", varRef("password")), blankStmt{})
+	}
+
+	// 直接パスワード指定
+	if auth.Value != "" {
+		return ttlBlock{commentStmt("Password authentication"), call("sendln", strLit(auth.Value)), blankStmt{}}
+	}
+
+	return nil
+}
+
+// generateDeviceSetup generates privileged mode escalation and init commands
+// defined by the profile's device type preset.
+func generateDeviceSetup(waitLogin bool, profileName, labelID string, profile *config.Profile, logging bool) ttlBlock {
+	preset, ok := config.GetDevicePreset(profile.DeviceType)
+	if !ok {
+		return nil
+	}
+
+	var b ttlBlock
+
+	if profile.Enable != nil && preset.EnableCommand != "" {
+		// 2段目以降はログイン直後のプロンプトを待ってから特権モードへ移行
+		if waitLogin {
+			b = append(b, waitPrompt(profile.LoginPrompt(), "TIMEOUT_"+labelID)...)
+		}
+		b = append(b, pauseLog(generateEnable(profileName, labelID, profile, preset), logging)...)
+	}
+
+	if len(preset.InitCommands) > 0 {
+		b = append(b, commentStmt(fmt.Sprintf("Device initialization (%s)", profile.DeviceType)))
+		b = append(b, generateCommands(preset.InitCommands, profile.PromptMarker, labelID)...)
+	}
+
+	return b
+}
+
+func generateEnable(profileName, labelID string, profile *config.Profile, preset *config.DevicePreset) ttlBlock {
+	enable := profile.Enable
+	timeoutLabel := "TIMEOUT_" + labelID
+
+	title := commentStmt("Enable privileged mode")
+	password := ttlExpr(strLit(enable.Value))
+
+	// password_fileが設定されている場合（デフォルト値含む）
+	if enable.PasswordFile != "" {
+		title = commentStmt("Enable privileged mode (from password file)")
+		password = varRef("enablepassword")
+	}
+
+	b := ttlBlock{title, call("sendln", strLit(preset.EnableCommand))}
+	b = append(b, waitFor(timeoutLabel, strLit(preset.EnablePrompt))...)
+	if enable.PasswordFile != "" {
+		// password name = profile name + "_enable"
+		b = append(b, call("getpassword", strLit(enable.PasswordFile), strLit(profileName+"_enable"), varRef("enablepassword")))
+	}
+	b = append(b, call("sendln", password))
+	b = append(b, waitPrompt(profile.PromptMarker, timeoutLabel)...)
+
+	return b
+}
+
+// errorMessage shows a message box and jumps to the cleanup.
+// format is a sprintf2 format applied to args, or the message itself when there are no args.
+func errorMessage(label, format string, args ...ttlExpr) ttlBlock {
+	b := ttlBlock{labelStmt(label)}
+	if len(args) == 0 {
+		b = append(b, call("messagebox", strLit(format), strLit("Error")))
+	} else {
+		b = append(b,
+			call("sprintf2", append([]ttlExpr{varRef("errormsg"), strLit(format)}, args...)...),
+			call("messagebox", varRef("errormsg"), strLit("Error")),
+		)
+	}
+	return append(b, gotoStmt("CLEANUP"), blankStmt{})
+}
+
+// retryAttempt jumps to errorLabel once all attempts have failed, and otherwise
+// waits for the retry interval, growing it by the backoff factor.
+// recover is the cleanup of the failed attempt before the wait.
+func retryAttempt(label, errorLabel, retryLabel string, recover ttlBlock) ttlBlock {
+	b := ttlBlock{
+		labelStmt(label),
+		gotoIf(binary(varRef("attempt"), ">", varRef("retrymax")), errorLabel),
+	}
+	b = append(b, recover...)
+	return append(b,
+		call("pause", varRef("retrywait")),
+		assign(varRef("retrywait"), binary(varRef("retrywait"), "*", varRef("retrybackoff"))),
+		gotoStmt(retryLabel),
+		blankStmt{},
+	)
+}
+
+// closeFailedSession closes the session of a failed first-step attempt.
+func closeFailedSession() ttlBlock {
+	return ttlBlock{
+		commentStmt("Close the failed session before retrying"),
+		call("testlink"),
+		ifThen(resultIs(">", 0), call("closett")),
+	}
+}
+
+// returnToPreviousHop returns from a failed ssh attempt of hop (0-based index in the
+// hops of the route) to the shell of the previous hop, so that the retry never runs ssh
+// on the target itself. Ctrl+C aborts an ssh that is still authenticating, and the ssh
+// escape sequence closes a session that has already logged in; both work whether or
+// not the prompts of the two hops differ. The escape is read by the outermost ssh client
+// first, so it is sent with one ~ per ssh client in the chain (~. at hop 2, ~~. at hop 3)
+// to close only the failing session. When the previous prompt still does not come back,
+// the step's disconnect command is sent, and errorLabel is taken if that fails too.
+func returnToPreviousHop(cfg *config.Config, hop int, previous, step *config.RouteStep, errorLabel string) ttlBlock {
+	previousPrompt := strLit(cfg.Profiles[previous.Profile].PromptMarker)
+	escape := sshEscape(hop)
+	disconnectCommand := "exit"
+	if preset, ok := config.GetDevicePreset(cfg.Profiles[step.Profile].DeviceType); ok {
+		disconnectCommand = preset.DisconnectCommand
+	}
+
+	return ttlBlock{
+		commentStmt("Abort the pending ssh session and return to the previous hop"),
+		commentStmt("(Ctrl+C stops ssh during authentication, " + escape + " closes a logged-in session)"),
+		call("send", intLit(3)),
+		call("sendln", strLit("")),
+		call("send", strLit(escape)),
+		call("send", intLit(3)), // 前段のコマンドラインに残った入力を破棄
+		call("wait", previousPrompt),
+		ifThen(resultIs("=", 0),
+			commentStmt("The session did not close: leave it with the disconnect command"),
+			call("send", intLit(3)),
+			call("sendln", strLit(disconnectCommand)),
+			call("wait", previousPrompt),
+			gotoIf(resultIs("=", 0), errorLabel),
+		),
+	}
+}
+
+// sshEscape returns the ssh escape sequence that closes the session of hop (0-based index
+// in the hops of the route). Each ssh client in front of it passes ~~ on as a single ~.
+func sshEscape(hop int) string {
+	return strings.Repeat("~", hop) + "."
+}
+
+// generateErrorHandling generates the retry and error labels of each step.
+// targets are the labels the macro body jumps to; a TIMEOUT label that nothing
+// jumps to (e.g. when every wait of the step goes through the retry path) is omitted.
+func generateErrorHandling(cfg *config.Config, errorLabels []string, route []*config.RouteStep, targets map[string]bool) ttlBlock {
+	var b ttlBlock
+	retry := retryEnabled(cfg)
 
 	for i, label := range errorLabels {
 		profileName := route[i].Profile
 
+		// リトライ処理（上限到達時は試行回数付きのエラーへ遷移）
+		if retry {
+			if i == 0 {
+				b = append(b, retryAttempt("RETRY_CONNECT_"+label, "ERROR_CONNECT_"+label, "CONNECT_"+label, closeFailedSession())...)
+				b = append(b, retryAttempt("RETRY_"+label, "ERROR_RETRY_"+label, "CONNECT_"+label, closeFailedSession())...)
+			} else {
+				abort := returnToPreviousHop(cfg, i, route[i-1], route[i], "ERROR_RETRY_"+label)
+				b = append(b, retryAttempt("RETRY_"+label, "ERROR_RETRY_"+label, "SSH_"+label, abort)...)
+			}
+			b = append(b, errorMessage("ERROR_RETRY_"+label, "Connection timeout: "+profileName+" (attempt %d of %d)", varRef("attempt"), varRef("retryattempts"))...)
+		}
+
 		// 接続エラー（最初のステップのみ）
 		if i == 0 {
-			sb.WriteString(fmt.Sprintf(errorConnectTemplate, label, profileName))
+			if retry {
+				b = append(b, errorMessage("ERROR_CONNECT_"+label, "Failed to connect to "+profileName+" (attempt %d of %d)", varRef("attempt"), varRef("retryattempts"))...)
+			} else {
+				b = append(b, errorMessage("ERROR_CONNECT_"+label, "Failed to connect to "+profileName)...)
+			}
+		}
+
+		// コマンド失敗（終了コード確認を行うステップのみ）
+		if stepChecksExit(route[i]) {
+			b = append(b, errorMessage("ERROR_COMMAND_"+label, "Command failed on "+profileName+" (exit code %d): %s", varRef("exitcode"), varRef("failedcmd"))...)
+		}
+
+		// 出力検証失敗（検証を行うステップのみ）
+		if stepHasAssertions(route[i]) {
+			b = append(b, errorMessage("ERROR_ASSERT_"+label, "Assertion failed on "+profileName+": %s", varRef("assertmsg"))...)
+		}
+
+		// ファイル転送失敗（完了確認・チェックサム照合を行うステップのみ）
+		if stepChecksTransfer(route[i]) {
+			b = append(b, errorMessage("ERROR_TRANSFER_"+label, "File transfer failed on "+profileName+": %s", varRef("transfermsg"))...)
 		}
 
 		// タイムアウトエラー
-		sb.WriteString(fmt.Sprintf(errorTimeoutTemplate, label, profileName))
+		if targets["TIMEOUT_"+label] {
+			b = append(b, errorMessage("TIMEOUT_"+label, "Connection timeout: "+profileName)...)
+		}
 	}
 
 	// クリーンアップ
-	sb.WriteString(cleanupTemplate)
-
-	return sb.String()
+	b = append(b, labelStmt("CLEANUP"))
+	b = append(b, logClose(cfg)...)
+	return append(b, call("closett"), call("end"))
 }
 
 // generateAutoDisconnect generates disconnect sequence for all route steps.
-func generateAutoDisconnect(routeSteps int) string {
-	var sb strings.Builder
-
-	sb.WriteString("; === Auto Disconnect ===\n")
-
-	// 多段接続の場合、すべての接続を順次exit
-	if routeSteps > 1 {
-		for i := routeSteps - 1; i > 0; i-- {
-			sb.WriteString(fmt.Sprintf("; Disconnect from step %d\n", i+1))
-			sb.WriteString("sendln 'exit'\n")
-			sb.WriteString("pause 1\n") // 切断処理の完了を待つ
+// Jump steps of a proxyjump route have no shell session and need no exit.
+func generateAutoDisconnect(cfg *config.Config, route *config.Route) ttlBlock {
+	b := ttlBlock{commentStmt("=== Auto Disconnect ===")}
+
+	// 多段接続の場合、すべての接続を順次切断（デバイス種別ごとの切断コマンドを使用）
+	steps := route.Steps
+	if len(steps) > 1 {
+		for i := len(steps) - 1; i > 0; i-- {
+			if route.IsJumpStep(i) {
+				continue
+			}
+			disconnectCommand := "exit"
+			if preset, ok := config.GetDevicePreset(cfg.Profiles[steps[i].Profile].DeviceType); ok {
+				disconnectCommand = preset.DisconnectCommand
+			}
+			disconnect := ttlBlock{
+				call("sendln", strLit(disconnectCommand)),
+				call("pause", intLit(1)), // 切断処理の完了を待つ
+			}
+			b = append(b, commentStmt(fmt.Sprintf("Disconnect from step %d", i+1)))
+			// when により接続しなかったステップは切断しない
+			if steps[i].When != nil {
+				b = append(b, ifThen(binary(stepResultVar(i+1), "<>", intLit(0)), disconnect...))
+			} else {
+				b = append(b, disconnect...)
+			}
 		}
-		sb.WriteString("\n")
+		b = append(b, blankStmt{})
 	}
 
 	// 成功終了（Tera Term終了）
-	sb.WriteString(successTemplate)
-
-	return sb.String()
+	b = append(b, labelStmt("SUCCESS"))
+	b = append(b, logClose(cfg)...)
+	return append(b, call("closett"), call("end"), blankStmt{})
 }
@@ -3,6 +3,7 @@ package generator
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/JHashimoto0518/ttlx/internal/config"
 	"github.com/stretchr/testify/assert"
@@ -36,7 +37,7 @@ func TestGenerate_Simple(t *testing.T) {
 	assert.Contains(t, ttl, "timeout = 30")
 
 	// 接続処理の確認（最初のステップ）
-	assert.Contains(t, ttl, ":CONNECT_BASTION")
+	assert.Contains(t, ttl, ":CONNECT_1_BASTION")
 	assert.Contains(t, ttl, "getpassword 'passwords.dat' 'bastion' password")
 	assert.Contains(t, ttl, "strconcat connectcmd 'bastion.example.com:22 /ssh /auth=password /user=user1")
 	assert.Contains(t, ttl, "strconcat connectcmd password")
@@ -48,9 +49,9 @@ func TestGenerate_Simple(t *testing.T) {
 	assert.Contains(t, ttl, "getpassword 'passwords.dat' 'target' password")
 
 	// エラーハンドリングの確認
-	assert.Contains(t, ttl, ":ERROR_CONNECT_BASTION")
-	assert.Contains(t, ttl, ":TIMEOUT_BASTION")
-	assert.Contains(t, ttl, ":TIMEOUT_TARGET")
+	assert.Contains(t, ttl, ":ERROR_CONNECT_1_BASTION")
+	assert.Contains(t, ttl, ":TIMEOUT_1_BASTION")
+	assert.Contains(t, ttl, ":TIMEOUT_2_TARGET")
 	assert.Contains(t, ttl, ":CLEANUP")
 
 	// 成功終了の確認
@@ -79,7 +80,7 @@ func TestGenerate_Full(t *testing.T) {
 	assert.Contains(t, ttl, "timeout = 60")
 
 	// 接続処理の確認（パスワードファイル認証）
-	assert.Contains(t, ttl, ":CONNECT_BASTION")
+	assert.Contains(t, ttl, ":CONNECT_1_BASTION")
 	assert.Contains(t, ttl, "getpassword 'passwords.dat' 'bastion' password")
 	assert.Contains(t, ttl, "strconcat connectcmd 'bastion.example.com:22 /ssh /auth=password /user=user1")
 	assert.Contains(t, ttl, "strconcat connectcmd password")
@@ -90,7 +91,7 @@ func TestGenerate_Full(t *testing.T) {
 	assert.Contains(t, ttl, "sendln 'cd /var/log'")
 
 	// 2番目のステップ（公開鍵認証）の確認（ポート指定含む）
-	assert.Contains(t, ttl, "sendln 'ssh user2@10.0.0.50 -p 2222'")
+	assert.Contains(t, ttl, "sendln 'ssh -i \"~/.ssh/id_rsa\" user2@10.0.0.50 -p 2222'")
 	assert.Contains(t, ttl, "sendln 'ps aux'")
 	assert.Contains(t, ttl, "sendln 'df -h'")
 }
@@ -110,10 +111,10 @@ func TestGenerate_WithKeyfile(t *testing.T) {
 				},
 			},
 		},
-		Routes: map[string][]*config.RouteStep{
-			"test-route": {
+		Routes: map[string]*config.Route{
+			"test-route": {Steps: []*config.RouteStep{
 				{Profile: "server"},
-			},
+			}},
 		},
 		Options: &config.Options{
 			Timeout: 30,
@@ -147,10 +148,10 @@ func TestGenerate_PasswordValue(t *testing.T) {
 				},
 			},
 		},
-		Routes: map[string][]*config.RouteStep{
-			"test-route": {
+		Routes: map[string]*config.Route{
+			"test-route": {Steps: []*config.RouteStep{
 				{Profile: "server"},
-			},
+			}},
 		},
 		Options: &config.Options{
 			Timeout: 30,
@@ -163,16 +164,30 @@ func TestGenerate_PasswordValue(t *testing.T) {
 	ttl := results["test-route"]
 
 	// 直接指定されたパスワードの確認 - connect コマンドに含まれる
-	assert.Contains(t, ttl, "connect 'server.example.com:22 /ssh /auth=password /user=user /passwd=secret123'")
+	assert.Contains(t, ttl, "connect 'server.example.com:22 /ssh /auth=password /user=user /passwd=\"secret123\"'")
 	// 別途の sendln でパスワード送信がないことを確認
 	assert.NotContains(t, ttl, "sendln 'secret123'")
 }
 
 func TestGenerate_Components(t *testing.T) {
 	t.Run("generateHeader", func(t *testing.T) {
-		header := generateHeader("test.yml", "test-route")
+		header := generateHeader("test.yml", "test-route", "", Options{Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}).String()
 		assert.Contains(t, header, "Generated by ttlx")
 		assert.Contains(t, header, "Source: test.yml")
+		assert.Contains(t, header, "; Generated at: 2024-01-02 03:04:05\n")
+		assert.NotContains(t, header, "SHA-256")
+	})
+
+	t.Run("generateHeader without timestamp", func(t *testing.T) {
+		header := generateHeader("test.yml", "test-route", "", Options{SourceHash: SourceHash([]byte("version: \"1.0\"\n"))}).String()
+		assert.NotContains(t, header, "Generated at")
+		assert.Contains(t, header, "; Source: test.yml\n; Source SHA-256: ")
+		assert.Regexp(t, `; Source SHA-256: [0-9a-f]{64}\n; Route: test-route\n`, header)
+	})
+
+	t.Run("generateHeader with description", func(t *testing.T) {
+		header := generateHeader("test.yml", "test-route", "Production DB\nvia bastion\n", Options{}).String()
+		assert.Contains(t, header, "; Route: test-route\n; Description: Production DB\n;   via bastion\n; ====")
 	})
 
 	t.Run("generateVariables", func(t *testing.T) {
@@ -181,13 +196,13 @@ func TestGenerate_Components(t *testing.T) {
 				Timeout: 60,
 			},
 		}
-		vars := generateVariables(cfg)
+		vars := generateVariables(cfg).String()
 		assert.Contains(t, vars, "timeout = 60")
 	})
 
 	t.Run("generateVariables with default", func(t *testing.T) {
 		cfg := &config.Config{}
-		vars := generateVariables(cfg)
+		vars := generateVariables(cfg).String()
 		assert.Contains(t, vars, "timeout = 30")
 	})
 }
@@ -274,11 +289,23 @@ func TestGenerate_AutoDisconnect(t *testing.T) {
 }
 
 // buildTestConfig builds a test configuration.
+func TestGenerate_AutoDisconnectSkippedStep(t *testing.T) {
+	cfg := buildTestConfig(boolPtr(true), 2)
+	cfg.Routes["test-route"].Steps[1].When = &config.Condition{Step: 1, Result: "success"}
+	require.NoError(t, config.Validate(cfg))
+
+	results, err := GenerateAll(cfg, "test.yml")
+	require.NoError(t, err)
+
+	// when で接続しなかった場合は切断コマンドを送信しない
+	assert.Contains(t, results["test-route"], "; Disconnect from step 2\nif stepresult2 <> 0 then\n    sendln 'exit'\n    pause 1\nendif\n")
+}
+
 func buildTestConfig(autoDisconnect *bool, routeSteps int) *config.Config {
 	cfg := &config.Config{
 		Version:  "1.0",
 		Profiles: make(map[string]*config.Profile),
-		Routes:   make(map[string][]*config.RouteStep),
+		Routes:   make(map[string]*config.Route),
 		Options: &config.Options{
 			Timeout:        30,
 			AutoDisconnect: autoDisconnect,
@@ -310,6 +337,265 @@ func buildTestConfig(autoDisconnect *bool, routeSteps int) *config.Config {
 		})
 	}
 
-	cfg.Routes["test-route"] = route
+	cfg.Routes["test-route"] = &config.Route{Steps: route}
 	return cfg
 }
+
+func TestGenerate_RetryDisabled(t *testing.T) {
+	cfg := buildTestConfig(nil, 2)
+
+	results, err := GenerateAllWithOptions(cfg, "test.yml", Options{})
+	require.NoError(t, err)
+
+	ttl := results["test-route"]
+	assert.NotContains(t, ttl, "RETRY_")
+	assert.NotContains(t, ttl, "retrymax")
+	assert.Contains(t, ttl, "goto ERROR_CONNECT_1_SERVERA")
+}
+
+func TestGenerate_Logging(t *testing.T) {
+	t.Run("default log file name", func(t *testing.T) {
+		cfg := buildTestConfig(nil, 1)
+		cfg.Options.Log = true
+
+		results, err := GenerateAllWithOptions(cfg, "test.yml", Options{})
+		require.NoError(t, err)
+
+		ttl := results["test-route"]
+		assert.Contains(t, ttl, "logfile = 'test-route_'\nstrconcat logfile logdate\nstrconcat logfile '_'\nstrconcat logfile logtime\nstrconcat logfile '.log'")
+		assert.Contains(t, ttl, "logopen logfile 0 0 1 0")
+	})
+
+	t.Run("log disabled", func(t *testing.T) {
+		cfg := buildTestConfig(boolPtr(true), 2)
+
+		results, err := GenerateAllWithOptions(cfg, "test.yml", Options{})
+		require.NoError(t, err)
+
+		ttl := results["test-route"]
+		assert.NotContains(t, ttl, "logopen")
+		assert.NotContains(t, ttl, "logpause")
+		assert.NotContains(t, ttl, "logclose")
+	})
+}
+
+func TestSplitCaptureReferences(t *testing.T) {
+	captured := map[string]bool{"ver": true}
+
+	assert.Equal(t, []ttlExpr{strLit("uname -a")}, splitCaptureReferences("uname -a", captured))
+	assert.Equal(t, []ttlExpr{strLit("echo ${unknown}")}, splitCaptureReferences("echo ${unknown}", captured))
+	assert.Equal(t, []ttlExpr{strLit("echo "), varRef("cap_ver")}, splitCaptureReferences("echo ${ver}", captured))
+	assert.Equal(t, []ttlExpr{varRef("cap_ver"), strLit("-"), varRef("cap_ver")}, splitCaptureReferences("${ver}-${ver}", captured))
+}
+
+func TestGenerate_FlowControlNotUsed(t *testing.T) {
+	cfg := buildTestConfig(nil, 2)
+
+	results, err := GenerateAllWithOptions(cfg, "test.yml", Options{})
+	require.NoError(t, err)
+
+	ttl := results["test-route"]
+	assert.NotContains(t, ttl, "Flow Control")
+	assert.NotContains(t, ttl, "stepresult")
+	assert.NotContains(t, ttl, ":STEP_")
+}
+
+func TestGenerate_ForeachOnFailure(t *testing.T) {
+	cfg := buildTestConfig(nil, 1)
+	cfg.Routes["test-route"].Steps[0].Commands = []*config.Command{
+		{Foreach: &config.Foreach{
+			Items: []string{"a", "b"},
+			Commands: []*config.Command{
+				{Run: "check ${item}", CheckExit: boolPtr(true), OnFailure: "continue"},
+			},
+		}},
+	}
+
+	results, err := GenerateAll(cfg, "test.yml")
+	require.NoError(t, err)
+
+	// 展開された反復ごとに失敗時のラベルが一意になる
+	ttl := results["test-route"]
+	assert.Contains(t, ttl, ":FAIL_1_1_1_1\n; On failure: continue\nstepresult1 = 2\ngoto NEXT_1_1_1_1")
+	assert.Contains(t, ttl, ":FAIL_1_1_2_1\n; On failure: continue\nstepresult1 = 2\ngoto NEXT_1_1_2_1")
+}
+
+func TestGenerate_TransferWithoutWait(t *testing.T) {
+	cfg := buildTestConfig(nil, 1)
+	cfg.Routes["test-route"].Steps[0].Transfer = &config.Transfer{
+		Direction: "upload", Local: "a.txt", Remote: "/tmp/a.txt", Wait: boolPtr(false),
+	}
+
+	results, err := GenerateAllWithOptions(cfg, "test.yml", Options{})
+	require.NoError(t, err)
+
+	ttl := results["test-route"]
+	assert.Contains(t, ttl, "scpsend 'a.txt' '/tmp/a.txt'")
+	assert.NotContains(t, ttl, "TRANSFER_WAIT")
+	assert.NotContains(t, ttl, "ERROR_TRANSFER")
+}
+
+func TestSerialTransferCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer *config.Transfer
+		remote   string
+		local    string
+	}{
+		{
+			name:     "zmodem upload with same name",
+			transfer: &config.Transfer{Protocol: "zmodem", Direction: "upload", Local: `C:\work\app.conf`, Remote: "/etc/app/app.conf"},
+			remote:   `(cd "/etc/app" && rz -y)`,
+			local:    "zmodemsend 'C:\\work\\app.conf' 1\n",
+		},
+		{
+			name:     "kermit upload with rename",
+			transfer: &config.Transfer{Protocol: "kermit", Direction: "upload", Local: "app.conf", Remote: "/tmp/new.conf"},
+			remote:   `(cd "/tmp" && kermit -r && mv -f "app.conf" "new.conf")`,
+			local:    "kermitsend 'app.conf'\n",
+		},
+		{
+			name:     "xmodem upload",
+			transfer: &config.Transfer{Protocol: "xmodem", Direction: "upload", Local: "fw.bin", Remote: "/tmp/fw.bin"},
+			remote:   `rx "/tmp/fw.bin"`,
+			local:    "xmodemsend 'fw.bin' 2\n",
+		},
+		{
+			name:     "zmodem download to current directory",
+			transfer: &config.Transfer{Protocol: "zmodem", Direction: "download", Local: "app.log", Remote: "/var/log/app.log"},
+			remote:   `sz "/var/log/app.log"`,
+			local:    "zmodemrecv\n",
+		},
+		{
+			name:     "xmodem download",
+			transfer: &config.Transfer{Protocol: "xmodem", Direction: "download", Local: "logs/app.log", Remote: "/var/log/app.log"},
+			remote:   `sx "/var/log/app.log"`,
+			local:    "xmodemrecv 'logs/app.log' 1 2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, local := serialTransferCommands(tt.transfer)
+			assert.Equal(t, tt.remote, remote)
+			assert.Equal(t, tt.local, local.String())
+		})
+	}
+}
+
+func TestGenerate_SSHOptions(t *testing.T) {
+	cfg := buildTestConfig(nil, 2)
+	cfg.Profiles["servera"].SSHOptions = &config.SSHOptions{ForwardAgent: true}
+	cfg.Profiles["serverb"].Auth = &config.Auth{Type: "keyfile", Path: "~/.ssh/id_rsa"}
+	cfg.Profiles["serverb"].SSHOptions = &config.SSHOptions{
+		IdentityFile: "~/.ssh/id_target",
+		ForwardAgent: true,
+		Options: map[string]string{
+			"StrictHostKeyChecking": "no",
+			"ServerAliveInterval":   "30",
+			"ProxyCommand":          "nc -x proxy:1080 %h %p",
+		},
+		ExtraArgs: []string{"-C", "-q"},
+	}
+	require.NoError(t, config.Validate(cfg))
+
+	results, err := GenerateAll(cfg, "test.yml")
+	require.NoError(t, err)
+
+	ttl := results["test-route"]
+
+	// 1段目はエージェント転送のみ connect オプションに反映
+	assert.Contains(t, ttl, "/user=user /ssh-A /passwd=")
+
+	// 2段目以降: -o オプションは名前順、空白を含む値はクォート
+	assert.Contains(t, ttl, `sendln 'ssh -i "~/.ssh/id_target" -A -o "ProxyCommand=nc -x proxy:1080 %h %p" -o ServerAliveInterval=30 -o StrictHostKeyChecking=no -C -q user@example.com -p 22'`)
+
+	// 鍵認証はパスワード入力待機を行わない
+	assert.NotContains(t, ttl, "wait ''")
+}
+
+func TestGenerateCompletionWait_Heartbeat(t *testing.T) {
+	cmd := &config.Command{Run: "/opt/backup/run.sh", Timeout: "3600"}
+	keepalive := &config.Keepalive{Interval: 60, Method: "heartbeat"}
+
+	// heartbeat は入力を送信せず、timeout を変更して待機
+	code := generateCompletionWait(cmd, "2_1", "$ ", "TIMEOUT_APP", keepalive).String()
+	assert.Contains(t, code, "timeout = 3600\n; Keepalive: Tera Term SSH heartbeat\nwait '$ '\n")
+	assert.NotContains(t, code, "send 0")
+
+	// keepalive 未設定
+	code = generateCompletionWait(cmd, "2_1", "$ ", "TIMEOUT_APP", nil).String()
+	assert.Contains(t, code, "timeout = 3600\nwait '$ '\n")
+	assert.NotContains(t, code, "Keepalive")
+}
+
+func TestGenerate_QuotedValues(t *testing.T) {
+	cfg := &config.Config{
+		Version: "1.0",
+		Profiles: map[string]*config.Profile{
+			"bastion": {
+				Host:         "bastion.example.com",
+				Port:         22,
+				User:         "o'brien",
+				PromptMarker: "$ ",
+				Auth:         &config.Auth{Type: "password", Value: `pa'ss"word`},
+			},
+			"target": {
+				Host:         "10.0.0.20",
+				Port:         22,
+				User:         "admin",
+				PromptMarker: "admin's $ ",
+				Auth:         &config.Auth{Type: "password", PasswordFile: `C:\it's\passwords.dat`, PasswordPrompt: "admin's password:"},
+			},
+		},
+		Routes: map[string]*config.Route{
+			"quoted": {Steps: []*config.RouteStep{
+				{Profile: "bastion"},
+				{Profile: "target", Commands: []*config.Command{
+					{Run: `ps aux | awk '{print $1}'`},
+					{Run: `echo "it's"`, ExpectContains: "it's"},
+				}},
+			}},
+		},
+	}
+
+	results, err := GenerateAll(cfg, "quoted.yml")
+	require.NoError(t, err)
+	ttl := results["quoted"]
+
+	// 引用符を含む値は引用符の種類を切り替え、必要に応じて #39 で連結
+	assert.Contains(t, ttl, `connect 'bastion.example.com:22 /ssh /auth=password /user=o'#39'brien /passwd="pa'#39'ss""word"'`)
+	assert.Contains(t, ttl, `wait "admin's password:"`)
+	assert.Contains(t, ttl, `getpassword "C:\it's\passwords.dat" 'target' password`)
+	assert.Contains(t, ttl, "; Command: ps aux | awk '{print $1}'\nsendln \"ps aux | awk '{print $1}'\"\nwait \"admin's $ \"\n")
+	assert.Contains(t, ttl, `sendln 'echo "it'#39's"'`)
+	assert.Contains(t, ttl, `wait "it's" "admin's $ "`)
+	assert.Contains(t, ttl, `assertmsg = 'echo "it'#39's": expected output to contain "it'#39's"'`)
+}
+
+func TestTTSSHQuote(t *testing.T) {
+	// 空白や先頭の / を別のオプションとして解釈させない
+	assert.Equal(t, `"pass word"`, ttsshQuote("pass word"))
+	assert.Equal(t, `"/secret"`, ttsshQuote("/secret"))
+	assert.Equal(t, `"say ""hi"""`, ttsshQuote(`say "hi"`))
+}
+
+func TestGenerateConnect_PassphraseValue(t *testing.T) {
+	profile := &config.Profile{
+		Host:         "server.example.com",
+		Port:         22,
+		User:         "user",
+		PromptMarker: "$ ",
+		Auth:         &config.Auth{Type: "keyfile", Path: "~/.ssh/id_rsa", PassphraseValue: "secret"},
+	}
+
+	ttl := generateConnect(1, "server", "SERVER", profile, false).String()
+	assert.Contains(t, ttl, "connect 'server.example.com:22 /ssh /auth=keyfile /user=user /keyfile=~/.ssh/id_rsa /passwd=\"secret\"'")
+}
+
+func TestSSHEscape(t *testing.T) {
+	// 外側の ssh クライアントごとに ~ を1つ重ねる
+	assert.Equal(t, "~.", sshEscape(1))
+	assert.Equal(t, "~~.", sshEscape(2))
+	assert.Equal(t, "~~~.", sshEscape(3))
+}
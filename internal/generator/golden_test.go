@@ -0,0 +1,114 @@
+package generator
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// update rewrites golden files with the current output: go test ./internal/generator -update
+var update = flag.Bool("update", false, "update golden files")
+
+// fixturesDir contains the valid configurations whose output is compared with the golden files.
+const fixturesDir = "../../test/fixtures/valid"
+
+// assertGolden compares the generated TTL with testdata/<name>.golden.
+// The TTL must be generated without a timestamp so that the whole file is compared.
+func assertGolden(t *testing.T, name, ttl string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(ttl), 0o644))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "golden file not found (run with -update to create it)")
+	assert.Equal(t, string(expected), ttl)
+}
+
+// TestGenerate_Golden generates every route of every valid fixture and compares it
+// with testdata/<fixture>_<route>.golden.
+func TestGenerate_Golden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join(fixturesDir, "*.yml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, fixtures)
+
+	generated := make(map[string]bool)
+	for _, fixture := range fixtures {
+		file := filepath.Base(fixture)
+		cfg, err := config.LoadConfig(fixture)
+		require.NoError(t, err)
+		require.NoError(t, config.Validate(cfg), file)
+
+		results, err := GenerateAllWithOptions(cfg, file, Options{})
+		require.NoError(t, err, file)
+
+		routes := make([]string, 0, len(results))
+		for route := range results {
+			routes = append(routes, route)
+		}
+		sort.Strings(routes)
+
+		for _, route := range routes {
+			name := strings.TrimSuffix(file, ".yml") + "_" + route
+			generated[name] = true
+			t.Run(name, func(t *testing.T) {
+				assertGolden(t, name, results[route])
+			})
+		}
+	}
+
+	// 削除したルートのゴールデンファイルを残さない
+	goldens, err := filepath.Glob(filepath.Join("testdata", "*.golden"))
+	require.NoError(t, err)
+	for _, golden := range goldens {
+		name := strings.TrimSuffix(filepath.Base(golden), ".golden")
+		assert.True(t, generated[name], "stale golden file: %s", golden)
+	}
+}
+
+// ttlCommands are the Tera Term macro commands and keywords the generator may emit.
+var ttlCommands = map[string]bool{
+	"closett": true, "connect": true, "else": true, "end": true, "endif": true, "exec": true,
+	"fileclose": true, "filedelete": true, "fileopen": true, "filereadln": true, "filerename": true,
+	"filestat": true, "getdate": true, "getpassword": true, "gettime": true, "goto": true, "if": true,
+	"kermitrecv": true, "kermitsend": true, "logclose": true, "logopen": true, "logpause": true,
+	"logstart": true, "messagebox": true, "passwordbox": true, "pause": true, "recvln": true,
+	"scprecv": true, "scpsend": true, "send": true, "sendln": true, "setdir": true, "sprintf2": true,
+	"str2int": true, "strcompare": true, "strconcat": true, "strcopy": true, "strdim": true,
+	"strlen": true, "strremove": true, "strreplace": true, "strscan": true, "strtrim": true, "testlink": true,
+	"tolower": true, "wait": true, "waitregex": true, "xmodemrecv": true, "xmodemsend": true,
+	"zmodemrecv": true, "zmodemsend": true,
+}
+
+// TestGolden_Commands checks that the golden files only use Tera Term macro commands,
+// since a misspelled command is only reported when Tera Term runs the macro.
+func TestGolden_Commands(t *testing.T) {
+	goldens, err := filepath.Glob(filepath.Join("testdata", "*.golden"))
+	require.NoError(t, err)
+
+	for _, golden := range goldens {
+		content, err := os.ReadFile(golden)
+		require.NoError(t, err)
+
+		for i, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			// 空行・コメント・ラベル・代入は対象外
+			if len(fields) == 0 || strings.HasPrefix(fields[0], ";") || strings.HasPrefix(fields[0], ":") ||
+				(len(fields) > 1 && fields[1] == "=") {
+				continue
+			}
+			assert.True(t, ttlCommands[fields[0]], "%s:%d: unknown TTL command: %s", golden, i+1, fields[0])
+		}
+	}
+}
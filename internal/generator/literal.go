@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ttlString encodes s as a TTL string constant.
+//
+// The value is wrapped in single quotes, or in double quotes when it contains
+// single quotes but no double quotes. Characters that cannot appear inside the
+// chosen quotes (the quote itself and control characters) are emitted as
+// character constants such as #39, which TTL concatenates with the adjacent
+// literals: it's "quoted" -> 'it'#39's "quoted"'.
+func ttlString(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	quote := '\''
+	if strings.ContainsRune(s, '\'') && !strings.ContainsRune(s, '"') {
+		quote = '"'
+	}
+
+	var sb strings.Builder
+	open := false
+	for _, r := range s {
+		if r == quote || r < 0x20 || r == 0x7f {
+			if open {
+				sb.WriteRune(quote)
+				open = false
+			}
+			sb.WriteString(fmt.Sprintf("#%d", r))
+			continue
+		}
+		if !open {
+			sb.WriteRune(quote)
+			open = true
+		}
+		sb.WriteRune(r)
+	}
+	if open {
+		sb.WriteRune(quote)
+	}
+	return sb.String()
+}
+
+// ttlComment makes s safe to embed in a TTL comment line.
+// Line breaks and other control characters would end the comment and turn the
+// rest of the value into macro code, so they are replaced with spaces.
+func ttlComment(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseTTLString decodes a TTL string constant made of quoted literals and
+// #nn character constants, as Tera Term's macro parser does.
+func parseTTLString(s string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '\'', '"':
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				return "", fmt.Errorf("unterminated literal at %d", i)
+			}
+			sb.WriteString(s[i+1 : i+1+end])
+			i += end + 2
+		case '#':
+			j := i + 1
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			code, err := strconv.Atoi(s[i+1 : j])
+			if err != nil {
+				return "", fmt.Errorf("invalid character constant at %d", i)
+			}
+			sb.WriteRune(rune(code))
+			i = j
+		default:
+			return "", fmt.Errorf("unexpected %q at %d", s[i], i)
+		}
+	}
+	return sb.String(), nil
+}
+
+func TestTTLString(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "plain", value: "uptime", expected: "'uptime'"},
+		{name: "empty", value: "", expected: "''"},
+		{name: "double quotes", value: `echo "hi"`, expected: `'echo "hi"'`},
+		{name: "single quote", value: "echo it's", expected: `"echo it's"`},
+		{name: "awk", value: `awk '{print $1}'`, expected: `"awk '{print $1}'"`},
+		{name: "both quotes", value: `echo "it's"`, expected: `'echo "it'#39's"'`},
+		{name: "leading quote", value: `'"`, expected: `#39'"'`},
+		{name: "control characters", value: "a\tb\r\n", expected: "'a'#9'b'#13#10"},
+		{name: "multibyte", value: "再起動", expected: "'再起動'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ttlString(tt.value))
+		})
+	}
+}
+
+func TestTTLString_RoundTrip(t *testing.T) {
+	roundTrip := func(s string) bool {
+		decoded, err := parseTTLString(ttlString(s))
+		return err == nil && decoded == s
+	}
+	require.NoError(t, quick.Check(roundTrip, &quick.Config{MaxCount: 2000}))
+
+	// 引用符・制御文字を多く含む文字列
+	quoted := func(parts []byte) bool {
+		alphabet := "'\"ab #\t\n"
+		var sb strings.Builder
+		for _, b := range parts {
+			sb.WriteByte(alphabet[int(b)%len(alphabet)])
+		}
+		return roundTrip(sb.String())
+	}
+	require.NoError(t, quick.Check(quoted, &quick.Config{MaxCount: 2000}))
+}
+
+func TestTTLString_SingleLine(t *testing.T) {
+	// 生成される定数は1行に収まり、制御文字を含まない
+	singleLine := func(s string) bool {
+		encoded := ttlString(s)
+		return !strings.ContainsFunc(encoded, func(r rune) bool { return r < 0x20 || r == 0x7f })
+	}
+	require.NoError(t, quick.Check(singleLine, nil))
+}
+
+func TestTTLComment(t *testing.T) {
+	assert.Equal(t, "echo a  b", ttlComment("echo a\r\nb"))
+	assert.Equal(t, "awk '{print $1}'", ttlComment("awk '{print $1}'"))
+
+	singleLine := func(s string) bool {
+		return !strings.ContainsAny(ttlComment(s), "\r\n")
+	}
+	require.NoError(t, quick.Check(singleLine, nil))
+}
@@ -0,0 +1,290 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+)
+
+// transferProtocolNames maps a transfer protocol to its display name in comments.
+var transferProtocolNames = map[string]string{
+	"scp":    "SCP",
+	"zmodem": "ZMODEM",
+	"kermit": "Kermit",
+	"xmodem": "XMODEM",
+}
+
+// generateTransfer generates a file transfer performed before the step's commands,
+// followed by an optional checksum verification.
+func generateTransfer(stepNum int, transfer *config.Transfer, prompt, labelID string) ttlBlock {
+	var b ttlBlock
+
+	if transfer.ProtocolName() == "scp" {
+		b = generateSCPTransfer(stepNum, transfer, prompt, labelID)
+	} else {
+		b = generateSerialTransfer(transfer, prompt, labelID)
+	}
+
+	if transfer.Checksum {
+		timeoutLabel := "TIMEOUT_" + labelID
+		b = append(b, commentStmt("Verify checksum (local: certutil, remote: sha256sum)"))
+		b = append(b, localChecksum(transfer.Local, labelID)...)
+		b = append(b,
+			call("sendln", strLit(fmt.Sprintf(`sha256sum "%s" | sed "s/^/TTLX_SUM:/"`, transfer.Remote))),
+			call("waitregex", strLit("TTLX_SUM:([0-9a-f]{64})")),
+			gotoIf(resultIs("=", 0), timeoutLabel),
+			assign(varRef("remotesum"), varRef("groupmatchstr1")),
+		)
+		b = append(b, waitFor(timeoutLabel, strLit(prompt))...)
+		b = append(b,
+			call("strcompare", varRef("localsum"), varRef("remotesum")),
+			ifThen(resultIs("<>", 0), transferFailure("Checksum mismatch: "+transfer.Remote, labelID)...),
+			blankStmt{},
+		)
+	}
+
+	return b
+}
+
+// checksumSuffix is appended to the local file name for the output of certutil.
+const checksumSuffix = ".sha256"
+
+// localChecksum computes the SHA-256 of the local file into localsum in lower case.
+// TTL has no SHA-256 command, so certutil is run through exec and only its hash line
+// is written to a temporary file, which is read back and deleted. Anything but a
+// 64-digit hash (e.g. certutil failed) is reported as a transfer error.
+func localChecksum(local, labelID string) ttlBlock {
+	sumFile := local + checksumSuffix
+	sumFileHandle := varRef("sumfile")
+	return ttlBlock{
+		call("exec", strLit(fmt.Sprintf(`cmd /c certutil -hashfile "%s" SHA256 | findstr /r /i /x "[0-9a-f]*" > "%s"`, local, sumFile)), strLit("hide"), intLit(1)),
+		call("fileopen", sumFileHandle, strLit(sumFile), intLit(0)),
+		ifThen(binary(sumFileHandle, "=", intLit(-1)), transferFailure("Failed to compute checksum: "+local, labelID)...),
+		call("filereadln", sumFileHandle, varRef("localsum")),
+		call("fileclose", sumFileHandle),
+		call("filedelete", strLit(sumFile)),
+		call("tolower", varRef("localsum"), varRef("localsum")),
+		call("strlen", varRef("localsum")),
+		ifThen(resultIs("<>", 64), transferFailure("Failed to compute checksum: "+local, labelID)...),
+	}
+}
+
+// transferFailure records the transfer error message and jumps to the step's transfer error.
+func transferFailure(message, labelID string) ttlBlock {
+	return ttlBlock{
+		assign(varRef("transfermsg"), strLit(message)),
+		gotoStmt("ERROR_TRANSFER_" + labelID),
+	}
+}
+
+// remoteSize gets the size of the remote file into remotesize (-1 when it does not exist).
+func remoteSize(remote, prompt, labelID string) ttlBlock {
+	timeoutLabel := "TIMEOUT_" + labelID
+	b := ttlBlock{
+		call("sendln", strLit(fmt.Sprintf(`stat -c "TTLX_SIZE:%%s:" "%s" 2>/dev/null || echo "TTLX_SIZE:""-1:"`, remote))),
+		call("waitregex", strLit("TTLX_SIZE:(-?[0-9]+):")),
+		gotoIf(resultIs("=", 0), timeoutLabel),
+		call("str2int", varRef("remotesize"), varRef("groupmatchstr1")),
+	}
+	return append(b, waitFor(timeoutLabel, strLit(prompt))...)
+}
+
+// waitTransfer polls until the local and remote file sizes match, getting the
+// changing size with poll, and fails once the timeout expires.
+func waitTransfer(stepNum int, poll ttlBlock, cond ttlExpr, message, labelID string) ttlBlock {
+	waitLabel := fmt.Sprintf("TRANSFER_WAIT_%d", stepNum)
+	doneLabel := fmt.Sprintf("TRANSFER_DONE_%d", stepNum)
+	elapsed := varRef("transferelapsed")
+
+	b := ttlBlock{
+		commentStmt("Wait for transfer completion"),
+		assign(elapsed, intLit(0)),
+		labelStmt(waitLabel),
+	}
+	b = append(b, poll...)
+	return append(b,
+		gotoIf(cond, doneLabel),
+		assign(elapsed, binary(elapsed, "+", intLit(1))),
+		ifThen(binary(elapsed, ">", varRef("timeout")), transferFailure(message, labelID)...),
+		call("pause", intLit(1)),
+		gotoStmt(waitLabel),
+		labelStmt(doneLabel),
+		blankStmt{},
+	)
+}
+
+// partialSuffix is appended to the destination file name while an SCP transfer is in progress.
+const partialSuffix = ".part"
+
+// generateSCPTransfer generates an SCP file transfer on the first hop.
+// When the transfer waits for completion, the local and remote file sizes
+// are compared until they match or the timeout expires. A download is received
+// into a partial file that replaces the local file only once it is complete,
+// so a failed download keeps the previous copy.
+func generateSCPTransfer(stepNum int, transfer *config.Transfer, prompt, labelID string) ttlBlock {
+	var b ttlBlock
+
+	if transfer.Direction == "upload" {
+		if !transfer.Waits() {
+			return append(b,
+				commentStmt(fmt.Sprintf("Transfer (SCP upload): %s -> %s", transfer.Local, transfer.Remote)),
+				call("scpsend", strLit(transfer.Local), strLit(transfer.Remote)),
+				blankStmt{},
+			)
+		}
+		return generateSCPUpload(stepNum, transfer, prompt, labelID)
+	}
+
+	if !transfer.Waits() {
+		return append(b,
+			commentStmt(fmt.Sprintf("Transfer (SCP download): %s -> %s", transfer.Remote, transfer.Local)),
+			call("scprecv", strLit(transfer.Remote), strLit(transfer.Local)),
+			blankStmt{},
+		)
+	}
+
+	// 完了を確認するまで既存のファイルを残すため、一時ファイルに受信してから置き換える
+	partial := transfer.Local + partialSuffix
+	b = append(b, commentStmt("Check remote file"))
+	b = append(b, remoteSize(transfer.Remote, prompt, labelID)...)
+	b = append(b,
+		ifThen(binary(varRef("remotesize"), "<", intLit(0)), transferFailure("Remote file not found: "+transfer.Remote, labelID)...),
+		commentStmt("Remove a partial file left by an earlier run so that the size check sees the new file"),
+		call("filedelete", strLit(partial)),
+		blankStmt{},
+		commentStmt(fmt.Sprintf("Transfer (SCP download): %s -> %s", transfer.Remote, partial)),
+		call("scprecv", strLit(transfer.Remote), strLit(partial)),
+		blankStmt{},
+	)
+	poll := ttlBlock{
+		call("filestat", strLit(partial), varRef("localsize")),
+		ifThen(resultIs("<>", 0), assign(varRef("localsize"), intLit(-1))),
+	}
+	b = append(b, waitTransfer(
+		stepNum,
+		poll,
+		binary(varRef("localsize"), "=", varRef("remotesize")),
+		"Timed out waiting for download: "+transfer.Local,
+		labelID,
+	)...)
+	return append(b,
+		commentStmt("Replace the local file with the completed download"),
+		call("filedelete", strLit(transfer.Local)),
+		call("filerename", strLit(partial), strLit(transfer.Local)),
+		ifThen(resultIs("<>", 0), transferFailure("Failed to replace local file: "+transfer.Local, labelID)...),
+		blankStmt{},
+	)
+}
+
+// generateSCPUpload generates an SCP upload that waits for completion. The file is sent
+// to <remote>.part and moved into place once its size matches the local file, so that
+// an older remote file of the same size is never taken for the completed upload.
+func generateSCPUpload(stepNum int, transfer *config.Transfer, prompt, labelID string) ttlBlock {
+	timeoutLabel := "TIMEOUT_" + labelID
+	partial := transfer.Remote + partialSuffix
+
+	b := ttlBlock{
+		commentStmt("Check local file"),
+		call("filestat", strLit(transfer.Local), varRef("localsize")),
+		ifThen(resultIs("<>", 0), transferFailure("Local file not found: "+transfer.Local, labelID)...),
+		commentStmt("Remove a partial file left by an earlier run so that the size check sees the new file"),
+		call("sendln", strLit(fmt.Sprintf(`rm -f "%s"`, partial))),
+	}
+	b = append(b, waitFor(timeoutLabel, strLit(prompt))...)
+	b = append(b,
+		blankStmt{},
+		commentStmt(fmt.Sprintf("Transfer (SCP upload): %s -> %s", transfer.Local, partial)),
+		call("scpsend", strLit(transfer.Local), strLit(partial)),
+		blankStmt{},
+	)
+	b = append(b, waitTransfer(
+		stepNum,
+		remoteSize(partial, prompt, labelID),
+		binary(varRef("remotesize"), "=", varRef("localsize")),
+		"Timed out waiting for upload: "+transfer.Remote,
+		labelID,
+	)...)
+
+	// 終了コードはコマンドラインのエコーと一致しないよう引用符で区切って出力
+	b = append(b,
+		commentStmt("Replace the remote file with the completed upload"),
+		call("sendln", strLit(fmt.Sprintf(`mv -f "%s" "%s"; echo "TTLX_MV:"$?":"`, partial, transfer.Remote))),
+		call("waitregex", strLit("TTLX_MV:([0-9]+):")),
+		gotoIf(resultIs("=", 0), timeoutLabel),
+		call("str2int", varRef("mvstatus"), varRef("groupmatchstr1")),
+	)
+	b = append(b, waitFor(timeoutLabel, strLit(prompt))...)
+	return append(b,
+		ifThen(binary(varRef("mvstatus"), "<>", intLit(0)), transferFailure("Failed to replace remote file: "+transfer.Remote, labelID)...),
+		blankStmt{},
+	)
+}
+
+// generateSerialTransfer generates a ZMODEM, Kermit, or XMODEM transfer, which works
+// on any hop: the remote sender/receiver is started in the shell, then Tera Term
+// sends or receives the file over the terminal session and blocks until done.
+func generateSerialTransfer(transfer *config.Transfer, prompt, labelID string) ttlBlock {
+	protocol := transfer.ProtocolName()
+	remoteCommand, localCommand := serialTransferCommands(transfer)
+
+	from, to := transfer.Local, transfer.Remote
+	if transfer.Direction == "download" {
+		from, to = transfer.Remote, transfer.Local
+	}
+
+	b := ttlBlock{
+		commentStmt(fmt.Sprintf("Transfer (%s %s): %s -> %s", transferProtocolNames[protocol], transfer.Direction, from, to)),
+		call("sendln", strLit(remoteCommand)),
+	}
+	b = append(b, localCommand...)
+	return append(b, waitPrompt(prompt, "TIMEOUT_"+labelID)...)
+}
+
+// serialTransferCommands returns the remote shell command and the TTL commands for a
+// ZMODEM, Kermit, or XMODEM transfer.
+func serialTransferCommands(transfer *config.Transfer) (string, ttlBlock) {
+	remoteDir, remoteName := path.Dir(transfer.Remote), path.Base(transfer.Remote)
+
+	if transfer.Direction == "upload" {
+		// rz / kermit -r はカレントディレクトリにローカルのファイル名で保存するため、
+		// サブシェルで移動し、必要に応じてリネーム
+		receive := func(receiver string) string {
+			command := fmt.Sprintf(`cd "%s" && %s`, remoteDir, receiver)
+			if localName := transfer.LocalName(); localName != remoteName {
+				command += fmt.Sprintf(` && mv -f "%s" "%s"`, localName, remoteName)
+			}
+			return "(" + command + ")"
+		}
+
+		switch transfer.ProtocolName() {
+		case "zmodem":
+			return receive("rz -y"), ttlBlock{call("zmodemsend", strLit(transfer.Local), intLit(1))}
+		case "kermit":
+			return receive("kermit -r"), ttlBlock{call("kermitsend", strLit(transfer.Local))}
+		default: // xmodem
+			return fmt.Sprintf(`rx "%s"`, transfer.Remote), ttlBlock{call("xmodemsend", strLit(transfer.Local), intLit(2))}
+		}
+	}
+
+	// ZMODEM / Kermit の受信ファイルは Tera Term のカレントディレクトリに保存される
+	var setDir ttlBlock
+	if localDir := transfer.LocalDir(); localDir != "" {
+		setDir = ttlBlock{call("setdir", strLit(localDir))}
+	}
+
+	switch transfer.ProtocolName() {
+	case "zmodem":
+		return fmt.Sprintf(`sz "%s"`, transfer.Remote), append(setDir, call("zmodemrecv"))
+	case "kermit":
+		return fmt.Sprintf(`kermit -s "%s"`, transfer.Remote), append(setDir, call("kermitrecv"))
+	default: // xmodem
+		return fmt.Sprintf(`sx "%s"`, transfer.Remote), ttlBlock{call("xmodemrecv", strLit(transfer.Local), intLit(1), intLit(2))}
+	}
+}
+
+// stepChecksTransfer reports whether the step has a file transfer that can fail
+// with ERROR_TRANSFER (SCP completion wait or checksum verification).
+func stepChecksTransfer(step *config.RouteStep) bool {
+	return step.Transfer != nil && (step.Transfer.Waits() || step.Transfer.Checksum)
+}
@@ -0,0 +1,227 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ttlIndent is the indentation of statements nested in if blocks.
+const ttlIndent = "    "
+
+// ttlNode is a statement of the generated TTL macro.
+type ttlNode interface {
+	print(p *ttlPrinter)
+}
+
+// ttlExpr is an expression used as a command argument, an assigned value, or a condition.
+type ttlExpr interface {
+	expr() string
+}
+
+// ttlBlock is a sequence of statements.
+type ttlBlock []ttlNode
+
+// commentStmt is a comment line. Control characters in the text are replaced
+// so that the comment cannot end early and turn the rest into macro code.
+type commentStmt string
+
+// blankStmt is an empty line separating sections of the macro.
+type blankStmt struct{}
+
+// labelStmt defines a label. Each label may only be defined once in a macro.
+type labelStmt string
+
+// gotoStmt jumps to a label.
+type gotoStmt string
+
+// callStmt is a macro command with its arguments, e.g. sendln 'uptime'.
+type callStmt struct {
+	name string
+	args []ttlExpr
+}
+
+// assignStmt assigns a value to a variable or an array element.
+type assignStmt struct {
+	target ttlExpr
+	value  ttlExpr
+}
+
+// ifStmt is a multi-line if block with an optional else block.
+type ifStmt struct {
+	cond ttlExpr
+	then ttlBlock
+	els  ttlBlock
+}
+
+// strLit is a string constant, encoded with ttlString when printed.
+type strLit string
+
+// varRef refers to a variable.
+type varRef string
+
+// intLit is an integer constant.
+type intLit int
+
+// binaryExpr is an arithmetic or comparison expression, e.g. result <> 0.
+type binaryExpr struct {
+	left  ttlExpr
+	op    string
+	right ttlExpr
+}
+
+// indexExpr refers to an element of an array variable, e.g. items[0].
+type indexExpr struct {
+	array varRef
+	index ttlExpr
+}
+
+func (s strLit) expr() string     { return ttlString(string(s)) }
+func (v varRef) expr() string     { return string(v) }
+func (n intLit) expr() string     { return strconv.Itoa(int(n)) }
+func (e binaryExpr) expr() string { return e.left.expr() + " " + e.op + " " + e.right.expr() }
+func (e indexExpr) expr() string  { return fmt.Sprintf("%s[%s]", e.array, e.index.expr()) }
+
+// call builds a macro command statement.
+func call(name string, args ...ttlExpr) callStmt {
+	return callStmt{name: name, args: args}
+}
+
+// assign builds an assignment statement.
+func assign(target, value ttlExpr) assignStmt {
+	return assignStmt{target: target, value: value}
+}
+
+// binary builds a binary expression.
+func binary(left ttlExpr, op string, right ttlExpr) binaryExpr {
+	return binaryExpr{left: left, op: op, right: right}
+}
+
+// ifThen builds an if block without else.
+func ifThen(cond ttlExpr, then ...ttlNode) ifStmt {
+	return ifStmt{cond: cond, then: then}
+}
+
+// gotoIf builds an if block that jumps to target when cond holds.
+func gotoIf(cond ttlExpr, target string) ifStmt {
+	return ifThen(cond, gotoStmt(target))
+}
+
+// resultIs builds a comparison of the result system variable with n.
+func resultIs(op string, n int) binaryExpr {
+	return binary(varRef("result"), op, intLit(n))
+}
+
+// labelNamePattern matches a valid TTL label name.
+var labelNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ttlPrinter renders statements as TTL source.
+type ttlPrinter struct {
+	sb      strings.Builder
+	depth   int
+	labels  map[string]bool
+	targets []string // goto の遷移先（出現順）
+	err     error
+}
+
+func (p *ttlPrinter) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *ttlPrinter) line(s string) {
+	if s != "" {
+		p.sb.WriteString(strings.Repeat(ttlIndent, p.depth))
+		p.sb.WriteString(s)
+	}
+	p.sb.WriteString("\n")
+}
+
+func (b ttlBlock) print(p *ttlPrinter) {
+	for _, n := range b {
+		n.print(p)
+	}
+}
+
+func (c commentStmt) print(p *ttlPrinter) { p.line("; " + ttlComment(string(c))) }
+
+func (blankStmt) print(p *ttlPrinter) { p.line("") }
+
+func (l labelStmt) print(p *ttlPrinter) {
+	name := string(l)
+	switch {
+	case !labelNamePattern.MatchString(name) || len(name) > labelNameLimit:
+		p.fail(fmt.Errorf("invalid label: %s", name))
+	case p.labels[name]:
+		p.fail(fmt.Errorf("duplicate label: %s", name))
+	}
+	p.labels[name] = true
+	p.line(":" + name)
+}
+
+func (g gotoStmt) print(p *ttlPrinter) {
+	p.targets = append(p.targets, string(g))
+	p.line("goto " + string(g))
+}
+
+func (c callStmt) print(p *ttlPrinter) {
+	parts := make([]string, 0, len(c.args)+1)
+	parts = append(parts, c.name)
+	for _, arg := range c.args {
+		parts = append(parts, arg.expr())
+	}
+	p.line(strings.Join(parts, " "))
+}
+
+func (a assignStmt) print(p *ttlPrinter) { p.line(a.target.expr() + " = " + a.value.expr()) }
+
+func (s ifStmt) print(p *ttlPrinter) {
+	p.line("if " + s.cond.expr() + " then")
+	p.depth++
+	s.then.print(p)
+	p.depth--
+	if len(s.els) > 0 {
+		p.line("else")
+		p.depth++
+		s.els.print(p)
+		p.depth--
+	}
+	p.line("endif")
+}
+
+// render prints the block as TTL source.
+// It checks that every label is a valid name defined once and that every goto
+// target is defined, which TTL would only report when the jump is taken.
+func (b ttlBlock) render() (string, error) {
+	p := &ttlPrinter{labels: make(map[string]bool)}
+	b.print(p)
+	for _, target := range p.targets {
+		if !p.labels[target] {
+			p.fail(fmt.Errorf("undefined label: %s", target))
+		}
+	}
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.sb.String(), nil
+}
+
+// gotoTargets returns the labels the block jumps to.
+func (b ttlBlock) gotoTargets() map[string]bool {
+	p := &ttlPrinter{labels: make(map[string]bool)}
+	b.print(p)
+	targets := make(map[string]bool, len(p.targets))
+	for _, target := range p.targets {
+		targets[target] = true
+	}
+	return targets
+}
+
+// String prints the block as TTL source without checking labels.
+func (b ttlBlock) String() string {
+	p := &ttlPrinter{labels: make(map[string]bool)}
+	b.print(p)
+	return p.sb.String()
+}
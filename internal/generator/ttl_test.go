@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTTLBlock_Render(t *testing.T) {
+	rest := varRef("rest")
+	b := ttlBlock{
+		commentStmt("Split: a\r\nb"),
+		labelStmt("LOOP"),
+		call("strscan", rest, strLit("it's")),
+		ifStmt{
+			cond: resultIs("=", 0),
+			then: ttlBlock{assign(varRef("item"), rest)},
+			els: ttlBlock{
+				assign(varRef("len"), binary(varRef("result"), "-", intLit(1))),
+				ifThen(binary(varRef("len"), ">", intLit(0)), call("strcopy", rest, intLit(1), varRef("len"), varRef("item"))),
+			},
+		},
+		assign(indexExpr{array: varRef("items"), index: intLit(0)}, varRef("item")),
+		gotoStmt("LOOP"),
+		blankStmt{},
+	}
+
+	ttl, err := b.render()
+	require.NoError(t, err)
+	assert.Equal(t, `; Split: a  b
+:LOOP
+strscan rest "it's"
+if result = 0 then
+    item = rest
+else
+    len = result - 1
+    if len > 0 then
+        strcopy rest 1 len item
+    endif
+endif
+items[0] = item
+goto LOOP
+
+`, ttl)
+}
+
+func TestTTLBlock_DuplicateLabel(t *testing.T) {
+	b := ttlBlock{
+		labelStmt("TIMEOUT_WEB"),
+		ifThen(resultIs("=", 0), labelStmt("TIMEOUT_WEB")),
+	}
+
+	_, err := b.render()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate label: TIMEOUT_WEB")
+
+	// String は検査せずに出力
+	assert.Contains(t, b.String(), ":TIMEOUT_WEB\n")
+}
+
+func TestTTLBlock_UndefinedLabel(t *testing.T) {
+	b := ttlBlock{
+		gotoIf(resultIs("=", 0), "TIMEOUT_2_WEB"),
+		labelStmt("TIMEOUT_1_WEB"),
+	}
+
+	_, err := b.render()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "undefined label: TIMEOUT_2_WEB")
+}
+
+func TestTTLBlock_GotoTargets(t *testing.T) {
+	b := ttlBlock{
+		gotoIf(resultIs("=", 0), "TIMEOUT_2_WEB"),
+		ifThen(resultIs("<>", 2), gotoIf(resultIs("=", 0), "RETRY_2_WEB")),
+		labelStmt("TIMEOUT_1_WEB"),
+	}
+
+	assert.Equal(t, map[string]bool{"TIMEOUT_2_WEB": true, "RETRY_2_WEB": true}, b.gotoTargets())
+}
+
+func TestTTLBlock_InvalidLabel(t *testing.T) {
+	tests := []string{
+		"TIMEOUT_WEB-01",
+		"TIMEOUT_WEB.PROD",
+		"1_WEB",
+		"ERROR_TRANSFER_1_ABCDEFGHIJKLMNOPQ",
+	}
+
+	for _, label := range tests {
+		t.Run(label, func(t *testing.T) {
+			_, err := ttlBlock{labelStmt(label)}.render()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid label: "+label)
+		})
+	}
+}
+
+func TestStepLabelID(t *testing.T) {
+	tests := []struct {
+		name     string
+		stepNum  int
+		profile  string
+		expected string
+	}{
+		{name: "plain", stepNum: 1, profile: "bastion", expected: "1_BASTION"},
+		{name: "hyphen and dot", stepNum: 2, profile: "web-01.prod", expected: "2_WEB_01_PROD"},
+		{name: "truncated", stepNum: 12, profile: "very-long-profile-name-for-db", expected: "12_VERY_LONG_PROF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := stepLabelID(tt.stepNum, tt.profile)
+			assert.Equal(t, tt.expected, id)
+			assert.LessOrEqual(t, len(longestLabelPrefix+id), labelNameLimit)
+		})
+	}
+}
+
+func TestGenerate_RevisitedProfile(t *testing.T) {
+	profile := func(host string) *config.Profile {
+		return &config.Profile{
+			Host:         host,
+			Port:         22,
+			User:         "user",
+			PromptMarker: "$ ",
+			Auth:         &config.Auth{Type: "password", PasswordFile: "passwords.dat", PasswordPrompt: "password:"},
+		}
+	}
+	cfg := &config.Config{
+		Version: "1.0",
+		Profiles: map[string]*config.Profile{
+			"bastion":     profile("bastion.example.com"),
+			"web-01.prod": profile("10.0.0.10"),
+		},
+		Routes: map[string]*config.Route{
+			"loop": {Steps: []*config.RouteStep{
+				{Profile: "bastion"},
+				{Profile: "web-01.prod"},
+				{Profile: "bastion"},
+			}},
+		},
+	}
+
+	// 同じプロファイルを再度経由してもステップごとに別のラベルを生成
+	results, err := GenerateAll(cfg, "loop.yml")
+	require.NoError(t, err)
+	ttl := results["loop"]
+
+	assert.Contains(t, ttl, "wait 'password:'\nif result = 0 then\n    goto TIMEOUT_3_BASTION\nendif")
+	assert.Contains(t, ttl, ":TIMEOUT_1_BASTION\nmessagebox 'Connection timeout: bastion' 'Error'")
+	assert.Contains(t, ttl, ":TIMEOUT_2_WEB_01_PROD\nmessagebox 'Connection timeout: web-01.prod' 'Error'")
+	assert.Contains(t, ttl, ":TIMEOUT_3_BASTION\nmessagebox 'Connection timeout: bastion' 'Error'")
+}
@@ -0,0 +1,161 @@
+// Package graph renders the connection topology of a configuration as Graphviz DOT or Mermaid.
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+)
+
+// clientNode is the node Tera Term connects from.
+const clientNode = "Tera Term"
+
+// Node is a profile, or the Tera Term client.
+type Node struct {
+	Name  string
+	Label []string // 表示する行（プロファイル名、接続先）
+}
+
+// Edge is a connection made by one or more route steps with the same auth type.
+type Edge struct {
+	From   string
+	To     string
+	Auth   string
+	Routes []string
+}
+
+// Graph is the connection topology of the selected routes.
+type Graph struct {
+	Nodes []Node // 先頭は Tera Term、以降はプロファイル名順
+	Edges []Edge // From, To, Auth の順
+}
+
+// Build builds the topology of every route in cfg; select routes beforehand with
+// config.SelectRoutes and config.KeepRoutes to draw only some of them.
+// The first step of a route is an edge from Tera Term, and each later step is
+// an edge from the previous step's profile.
+func Build(cfg *config.Config) *Graph {
+	routes := make([]string, 0, len(cfg.Routes))
+	for name := range cfg.Routes {
+		routes = append(routes, name)
+	}
+	sort.Strings(routes)
+
+	profiles := make(map[string]bool)
+	edges := make(map[[3]string]*Edge)
+	for _, routeName := range routes {
+		route := cfg.Routes[routeName]
+		from := clientNode
+		for _, step := range route.Steps {
+			profiles[step.Profile] = true
+			auth := cfg.Profiles[step.Profile].Auth.Type
+			key := [3]string{from, step.Profile, auth}
+			edge, ok := edges[key]
+			if !ok {
+				edge = &Edge{From: from, To: step.Profile, Auth: auth}
+				edges[key] = edge
+			}
+			if len(edge.Routes) == 0 || edge.Routes[len(edge.Routes)-1] != routeName {
+				edge.Routes = append(edge.Routes, routeName)
+			}
+			from = step.Profile
+		}
+	}
+
+	g := &Graph{Nodes: []Node{{Name: clientNode, Label: []string{clientNode}}}}
+	for _, name := range sortedKeys(profiles) {
+		profile := cfg.Profiles[name]
+		g.Nodes = append(g.Nodes, Node{
+			Name:  name,
+			Label: []string{name, fmt.Sprintf("%s@%s:%d", profile.User, profile.Host, profile.Port)},
+		})
+	}
+
+	keys := make([][3]string, 0, len(edges))
+	for key := range edges {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		for k := range keys[i] {
+			if keys[i][k] != keys[j][k] {
+				return keys[i][k] < keys[j][k]
+			}
+		}
+		return false
+	})
+	for _, key := range keys {
+		g.Edges = append(g.Edges, *edges[key])
+	}
+	return g
+}
+
+// label returns the edge label, e.g. "backup, production (password)".
+func (e Edge) label() string {
+	return fmt.Sprintf("%s (%s)", strings.Join(e.Routes, ", "), e.Auth)
+}
+
+// DOT renders the graph in Graphviz DOT.
+func (g *Graph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph ttlx {\n")
+	sb.WriteString("    rankdir=LR;\n")
+	sb.WriteString("    node [shape=box];\n")
+	for _, n := range g.Nodes {
+		shape := ""
+		if n.Name == clientNode {
+			shape = ", shape=ellipse"
+		}
+		fmt.Fprintf(&sb, "    %s [label=%s%s];\n", dotString(n.Name), dotString(strings.Join(n.Label, "\n")), shape)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "    %s -> %s [label=%s];\n", dotString(e.From), dotString(e.To), dotString(e.label()))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart.
+func (g *Graph) Mermaid() string {
+	// プロファイル名の '.' などは Mermaid の ID に使えないため連番の ID を割り当てる
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.Name] = fmt.Sprintf("n%d", i)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for _, n := range g.Nodes {
+		label := mermaidString(strings.Join(n.Label, "<br/>"))
+		if n.Name == clientNode {
+			fmt.Fprintf(&sb, "    %s([%s])\n", ids[n.Name], label)
+		} else {
+			fmt.Fprintf(&sb, "    %s[%s]\n", ids[n.Name], label)
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "    %s -->|%s| %s\n", ids[e.From], mermaidString(e.label()), ids[e.To])
+	}
+	return sb.String()
+}
+
+// dotString quotes s as a DOT string, keeping newlines as line breaks in labels.
+func dotString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// mermaidString quotes s as a Mermaid label. Quotes are written as entity codes.
+func mermaidString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,105 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func load(t *testing.T, name string) *config.Config {
+	t.Helper()
+	cfg, err := config.LoadConfig("../../test/fixtures/valid/" + name)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(cfg))
+	return cfg
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(load(t, "multiple-routes.yml"))
+
+	assert.Equal(t, []Node{
+		{Name: "Tera Term", Label: []string{"Tera Term"}},
+		{Name: "backup-db", Label: []string{"backup-db", "dbuser@backup-db.internal:22"}},
+		{Name: "bastion", Label: []string{"bastion", "user1@bastion.example.com:22"}},
+		{Name: "prod-db", Label: []string{"prod-db", "dbuser@prod-db.internal:22"}},
+	}, g.Nodes)
+
+	// 同じ接続はルートをまとめて1本のエッジにする
+	assert.Equal(t, []Edge{
+		{From: "Tera Term", To: "bastion", Auth: "password", Routes: []string{"backup", "production"}},
+		{From: "bastion", To: "backup-db", Auth: "password", Routes: []string{"backup"}},
+		{From: "bastion", To: "prod-db", Auth: "password", Routes: []string{"production"}},
+	}, g.Edges)
+}
+
+func TestBuild_SelectedRoutes(t *testing.T) {
+	cfg := load(t, "multiple-routes.yml")
+	config.KeepRoutes(cfg, []string{"backup"})
+
+	g := Build(cfg)
+	assert.Len(t, g.Nodes, 3)
+	assert.Equal(t, []Edge{
+		{From: "Tera Term", To: "bastion", Auth: "password", Routes: []string{"backup"}},
+		{From: "bastion", To: "backup-db", Auth: "password", Routes: []string{"backup"}},
+	}, g.Edges)
+}
+
+func TestBuild_RevisitedProfile(t *testing.T) {
+	cfg := load(t, "multiple-routes.yml")
+	cfg.Routes["loop"] = &config.Route{Steps: []*config.RouteStep{
+		{Profile: "bastion"},
+		{Profile: "prod-db"},
+		{Profile: "bastion"},
+		{Profile: "prod-db"},
+	}}
+
+	config.KeepRoutes(cfg, []string{"loop"})
+
+	g := Build(cfg)
+	assert.Equal(t, []Edge{
+		{From: "Tera Term", To: "bastion", Auth: "password", Routes: []string{"loop"}},
+		{From: "bastion", To: "prod-db", Auth: "password", Routes: []string{"loop"}},
+		{From: "prod-db", To: "bastion", Auth: "password", Routes: []string{"loop"}},
+	}, g.Edges)
+}
+
+func TestGraph_DOT(t *testing.T) {
+	g := Build(load(t, "multiple-routes.yml"))
+
+	assert.Equal(t, `digraph ttlx {
+    rankdir=LR;
+    node [shape=box];
+    "Tera Term" [label="Tera Term", shape=ellipse];
+    "backup-db" [label="backup-db\ndbuser@backup-db.internal:22"];
+    "bastion" [label="bastion\nuser1@bastion.example.com:22"];
+    "prod-db" [label="prod-db\ndbuser@prod-db.internal:22"];
+    "Tera Term" -> "bastion" [label="backup, production (password)"];
+    "bastion" -> "backup-db" [label="backup (password)"];
+    "bastion" -> "prod-db" [label="production (password)"];
+}
+`, g.DOT())
+}
+
+func TestGraph_Mermaid(t *testing.T) {
+	cfg := load(t, "proxyjump.yml")
+	config.KeepRoutes(cfg, []string{"db-nested"})
+
+	g := Build(cfg)
+
+	assert.Equal(t, `flowchart LR
+    n0(["Tera Term"])
+    n1["bastion<br/>user1@bastion.example.com:22"]
+    n2["db<br/>dba@172.16.1.20:22"]
+    n3["dmz<br/>jump@10.0.0.10:22"]
+    n0 -->|"db-nested (password)"| n1
+    n1 -->|"db-nested (keyfile)"| n3
+    n3 -->|"db-nested (password)"| n2
+`, g.Mermaid())
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, `"a\"b\\c\nd"`, dotString("a\"b\\c\nd"))
+	assert.Equal(t, `"a#quot;b"`, mermaidString(`a"b`))
+}
@@ -0,0 +1,162 @@
+// Package inventory summarizes the routes and profiles of a configuration for ttlx list.
+package inventory
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+)
+
+// Route summarizes a route and its hop chain.
+type Route struct {
+	Name       string `json:"name"`
+	Strategy   string `json:"strategy"`
+	Steps      []Step `json:"steps"`
+	Commands   int    `json:"commands"`
+	OutputFile string `json:"output_file"`
+}
+
+// Step is a hop of a route.
+type Step struct {
+	Step     int    `json:"step"` // 1始まり
+	Profile  string `json:"profile"`
+	Auth     string `json:"auth"`
+	Jump     bool   `json:"jump,omitempty"` // proxyjump の中継ホスト
+	Commands int    `json:"commands"`
+}
+
+// Profile summarizes a profile and the routes that use it.
+type Profile struct {
+	Name       string   `json:"name"`
+	Host       string   `json:"host"`
+	Port       int      `json:"port"`
+	User       string   `json:"user"`
+	Auth       string   `json:"auth"`
+	DeviceType string   `json:"device_type"`
+	Routes     []string `json:"routes"`
+}
+
+// Usage is a route step that references a profile.
+type Usage struct {
+	Route string `json:"route"`
+	Step  int    `json:"step"` // 1始まり
+	Hops  int    `json:"hops"` // ルートのステップ数
+}
+
+// Profiles returns the chain of profile names, e.g. for "bastion → db".
+func (r Route) Profiles() []string {
+	names := make([]string, len(r.Steps))
+	for i, step := range r.Steps {
+		names[i] = step.Profile
+	}
+	return names
+}
+
+// AuthTypes returns the auth type of each step in order.
+func (r Route) AuthTypes() []string {
+	types := make([]string, len(r.Steps))
+	for i, step := range r.Steps {
+		types[i] = step.Auth
+	}
+	return types
+}
+
+// Routes summarizes the routes of a validated configuration, sorted by name.
+func Routes(cfg *config.Config) []Route {
+	routes := make([]Route, 0, len(cfg.Routes))
+	for _, name := range sortedKeys(cfg.Routes) {
+		route := cfg.Routes[name]
+		strategy := route.Strategy
+		if strategy == "" {
+			strategy = "nested"
+		}
+		r := Route{Name: name, Strategy: strategy, OutputFile: name + ".ttl", Steps: make([]Step, 0, len(route.Steps))}
+		for i, step := range route.Steps {
+			commands := countCommands(step)
+			r.Steps = append(r.Steps, Step{
+				Step:     i + 1,
+				Profile:  step.Profile,
+				Auth:     cfg.Profiles[step.Profile].Auth.Type,
+				Jump:     route.IsJumpStep(i),
+				Commands: commands,
+			})
+			r.Commands += commands
+		}
+		routes = append(routes, r)
+	}
+	return routes
+}
+
+// Profiles summarizes the profiles of a validated configuration, sorted by name.
+func Profiles(cfg *config.Config) []Profile {
+	profiles := make([]Profile, 0, len(cfg.Profiles))
+	for _, name := range sortedKeys(cfg.Profiles) {
+		profile := cfg.Profiles[name]
+		deviceType := profile.DeviceType
+		if deviceType == "" {
+			deviceType = "linux"
+		}
+
+		routes := []string{}
+		seen := make(map[string]bool)
+		for _, usage := range usages(cfg, name) {
+			if !seen[usage.Route] {
+				seen[usage.Route] = true
+				routes = append(routes, usage.Route)
+			}
+		}
+
+		profiles = append(profiles, Profile{
+			Name:       name,
+			Host:       profile.Host,
+			Port:       profile.Port,
+			User:       profile.User,
+			Auth:       profile.Auth.Type,
+			DeviceType: deviceType,
+			Routes:     routes,
+		})
+	}
+	return profiles
+}
+
+// WhereUsed returns every route step that references the profile, sorted by route and step.
+func WhereUsed(cfg *config.Config, profileName string) ([]Usage, error) {
+	if _, ok := cfg.Profiles[profileName]; !ok {
+		return nil, fmt.Errorf("profile not found: %s", profileName)
+	}
+	return usages(cfg, profileName), nil
+}
+
+func usages(cfg *config.Config, profileName string) []Usage {
+	result := []Usage{}
+	for _, name := range sortedKeys(cfg.Routes) {
+		route := cfg.Routes[name]
+		for i, step := range route.Steps {
+			if step.Profile == profileName {
+				result = append(result, Usage{Route: name, Step: i + 1, Hops: len(route.Steps)})
+			}
+		}
+	}
+	return result
+}
+
+// countCommands counts the commands sent to the host, including those in foreach loops.
+func countCommands(step *config.RouteStep) int {
+	count := 0
+	for _, cmd := range step.AllCommands() {
+		if cmd.Foreach == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
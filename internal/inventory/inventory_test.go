@@ -0,0 +1,124 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func load(t *testing.T, name string) *config.Config {
+	t.Helper()
+	cfg, err := config.LoadConfig("../../test/fixtures/valid/" + name)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(cfg))
+	return cfg
+}
+
+func TestRoutes(t *testing.T) {
+	routes := Routes(load(t, "multiple-routes.yml"))
+
+	require.Len(t, routes, 2)
+	assert.Equal(t, Route{
+		Name:     "backup",
+		Strategy: "nested",
+		Steps: []Step{
+			{Step: 1, Profile: "bastion", Auth: "password"},
+			{Step: 2, Profile: "backup-db", Auth: "password", Commands: 1},
+		},
+		Commands:   1,
+		OutputFile: "backup.ttl",
+	}, routes[0])
+	assert.Equal(t, "production", routes[1].Name)
+	assert.Equal(t, []string{"bastion", "prod-db"}, routes[1].Profiles())
+	assert.Equal(t, []string{"password", "password"}, routes[1].AuthTypes())
+}
+
+func TestRoutes_ProxyJump(t *testing.T) {
+	routes := Routes(load(t, "proxyjump.yml"))
+
+	require.Len(t, routes, 2)
+	direct := routes[0]
+	assert.Equal(t, "db-direct", direct.Name)
+	assert.Equal(t, "proxyjump", direct.Strategy)
+	assert.Equal(t, []bool{false, true, true, false}, []bool{direct.Steps[0].Jump, direct.Steps[1].Jump, direct.Steps[2].Jump, direct.Steps[3].Jump})
+}
+
+func TestRoutes_ForeachCommandsCounted(t *testing.T) {
+	routes := Routes(load(t, "foreach.yml"))
+
+	// foreach 自体は数えず、ループ内のコマンドを数える
+	require.Len(t, routes, 1)
+	assert.Equal(t, 5, routes[0].Commands)
+}
+
+func TestProfiles(t *testing.T) {
+	cfg := load(t, "multiple-routes.yml")
+	cfg.Profiles["unused"] = &config.Profile{Host: "unused.example.com", Port: 2222, User: "nobody", Auth: &config.Auth{Type: "keyfile"}}
+
+	profiles := Profiles(cfg)
+
+	require.Len(t, profiles, 4)
+	assert.Equal(t, Profile{
+		Name:       "bastion",
+		Host:       "bastion.example.com",
+		Port:       22,
+		User:       "user1",
+		Auth:       "password",
+		DeviceType: "linux",
+		Routes:     []string{"backup", "production"},
+	}, profiles[1])
+	assert.Equal(t, "unused", profiles[3].Name)
+	assert.Equal(t, []string{}, profiles[3].Routes)
+}
+
+func TestWhereUsed(t *testing.T) {
+	cfg := load(t, "multiple-routes.yml")
+	cfg.Routes["loop"] = &config.Route{Steps: []*config.RouteStep{
+		{Profile: "bastion"},
+		{Profile: "prod-db"},
+		{Profile: "bastion"},
+	}}
+
+	tests := []struct {
+		name     string
+		profile  string
+		expected []Usage
+		errMsg   string
+	}{
+		{
+			name:    "used by several routes and steps",
+			profile: "bastion",
+			expected: []Usage{
+				{Route: "backup", Step: 1, Hops: 2},
+				{Route: "loop", Step: 1, Hops: 3},
+				{Route: "loop", Step: 3, Hops: 3},
+				{Route: "production", Step: 1, Hops: 2},
+			},
+		},
+		{
+			name:     "single use",
+			profile:  "backup-db",
+			expected: []Usage{{Route: "backup", Step: 2, Hops: 2}},
+		},
+		{
+			name:    "unknown profile",
+			profile: "nope",
+			errMsg:  "profile not found: nope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usages, err := WhereUsed(cfg, tt.profile)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, usages)
+		})
+	}
+}
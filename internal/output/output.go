@@ -0,0 +1,104 @@
+// Package output converts generated TTL scripts to the character encoding and
+// line endings expected by Tera Term, and back for comparison.
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Override replaces the options.output settings of cfg with the non-empty
+// fields of override, e.g. the values of command-line flags.
+func Override(cfg *config.Config, override config.Output) {
+	if cfg.Options == nil {
+		cfg.Options = &config.Options{}
+	}
+	if cfg.Options.Output == nil {
+		cfg.Options.Output = &config.Output{}
+	}
+	if override.Encoding != "" {
+		cfg.Options.Output.Encoding = override.Encoding
+	}
+	if override.EOL != "" {
+		cfg.Options.Output.EOL = override.EOL
+	}
+}
+
+// utf8BOM is the byte order mark written for the utf8-bom encoding.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Encode converts a generated script to the given encoding and line endings.
+// A character that the encoding cannot represent is an error naming its line,
+// so that it is never replaced silently.
+func Encode(ttl string, format *config.Output) ([]byte, error) {
+	encoding, eol := settings(format)
+
+	var buf bytes.Buffer
+	if encoding == config.EncodingUTF8BOM {
+		buf.Write(utf8BOM)
+	}
+
+	lines := strings.SplitAfter(ttl, "\n")
+	for i, line := range lines {
+		if eol == config.EOLCRLF && strings.HasSuffix(line, "\n") && !strings.HasSuffix(line, "\r\n") {
+			line = strings.TrimSuffix(line, "\n") + "\r\n"
+		}
+		if encoding != config.EncodingSJIS {
+			buf.WriteString(line)
+			continue
+		}
+
+		encoded, err := japanese.ShiftJIS.NewEncoder().String(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, unencodable(line))
+		}
+		buf.WriteString(encoded)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode converts an existing script back to UTF-8 with LF line endings.
+// The byte order mark is removed, and a file that is not valid UTF-8 is read
+// as Shift_JIS, so scripts compare equal whichever encoding they were written in.
+func Decode(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	text := string(data)
+	if !utf8.Valid(data) {
+		decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode Shift_JIS: %w", err)
+		}
+		text = string(decoded)
+	}
+	return strings.ReplaceAll(text, "\r\n", "\n"), nil
+}
+
+// settings returns the encoding and line endings, applying the defaults.
+func settings(format *config.Output) (string, string) {
+	encoding, eol := config.EncodingUTF8, config.EOLLF
+	if format != nil {
+		if format.Encoding != "" {
+			encoding = format.Encoding
+		}
+		if format.EOL != "" {
+			eol = format.EOL
+		}
+	}
+	return encoding, eol
+}
+
+// unencodable describes the first character of line that Shift_JIS cannot represent.
+func unencodable(line string) string {
+	enc := japanese.ShiftJIS.NewEncoder()
+	for _, r := range line {
+		if _, err := enc.String(string(r)); err != nil {
+			return fmt.Sprintf("character %q (U+%04X) cannot be encoded in Shift_JIS", r, r)
+		}
+	}
+	return "line cannot be encoded in Shift_JIS"
+}
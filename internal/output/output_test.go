@@ -0,0 +1,80 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const script = "; Route: main\nsendln 'echo \"接続\"'\n"
+
+// sjisScript は script の Shift_JIS 表現（接 = 0x90DA, 続 = 0x91B1）
+const sjisScript = "; Route: main\nsendln 'echo \"\x90\xda\x91\xb1\"'\n"
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   *config.Output
+		expected string
+	}{
+		{name: "default", format: nil, expected: script},
+		{name: "utf8 lf", format: &config.Output{Encoding: config.EncodingUTF8, EOL: config.EOLLF}, expected: script},
+		{name: "utf8 crlf", format: &config.Output{EOL: config.EOLCRLF}, expected: "; Route: main\r\nsendln 'echo \"接続\"'\r\n"},
+		{name: "utf8-bom", format: &config.Output{Encoding: config.EncodingUTF8BOM}, expected: "\xef\xbb\xbf" + script},
+		{name: "sjis", format: &config.Output{Encoding: config.EncodingSJIS}, expected: sjisScript},
+		{name: "sjis crlf", format: &config.Output{Encoding: config.EncodingSJIS, EOL: config.EOLCRLF}, expected: "; Route: main\r\nsendln 'echo \"\x90\xda\x91\xb1\"'\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Encode(script, tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(data))
+		})
+	}
+}
+
+func TestEncode_UnencodableCharacter(t *testing.T) {
+	ttl := "; Route: main\nsendln 'ok'\nmessagebox 'done ✔' 'ttlx'\n"
+
+	_, err := Encode(ttl, &config.Output{Encoding: config.EncodingSJIS})
+	require.Error(t, err)
+	assert.Equal(t, "line 3: character '✔' (U+2714) cannot be encoded in Shift_JIS", err.Error())
+
+	// UTF-8 ではそのまま出力
+	data, err := Encode(ttl, &config.Output{Encoding: config.EncodingUTF8})
+	require.NoError(t, err)
+	assert.Equal(t, ttl, string(data))
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "utf8", input: script},
+		{name: "utf8-bom", input: "\xef\xbb\xbf" + script},
+		{name: "crlf", input: "; Route: main\r\nsendln 'echo \"接続\"'\r\n"},
+		{name: "sjis crlf", input: "; Route: main\r\nsendln 'echo \"\x90\xda\x91\xb1\"'\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, err := Decode([]byte(tt.input))
+			require.NoError(t, err)
+			assert.Equal(t, script, ttl)
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	cfg := &config.Config{}
+	Override(cfg, config.Output{})
+	assert.Equal(t, &config.Output{}, cfg.Options.Output)
+
+	cfg.Options.Output = &config.Output{Encoding: config.EncodingSJIS, EOL: config.EOLCRLF}
+	Override(cfg, config.Output{EOL: config.EOLLF})
+	assert.Equal(t, &config.Output{Encoding: config.EncodingSJIS, EOL: config.EOLLF}, cfg.Options.Output)
+}
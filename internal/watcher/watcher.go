@@ -0,0 +1,198 @@
+// Package watcher rebuilds the TTL scripts when the configuration file changes.
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/JHashimoto0518/ttlx/internal/differ"
+	"github.com/JHashimoto0518/ttlx/internal/generator"
+	"github.com/JHashimoto0518/ttlx/internal/output"
+)
+
+// Default timings of the watch loop.
+const (
+	DefaultInterval = 500 * time.Millisecond
+	DefaultDebounce = 300 * time.Millisecond
+)
+
+// Clock abstracts time so that tests can control polling and debouncing.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Watcher polls the configuration file and regenerates the scripts after it
+// has stopped changing for the debounce period.
+type Watcher struct {
+	ConfigPath string
+	OutputDir  string
+	Out        io.Writer // 再生成の結果とエラーの出力先
+	// Options returns the header options, evaluated on every rebuild.
+	Options func() (generator.Options, error)
+	// Output overrides options.output of the configuration, e.g. with command-line flags.
+	Output   config.Output
+	Clock    Clock
+	Interval time.Duration // ファイルを確認する間隔
+	Debounce time.Duration // 変更が止まってから再生成するまでの時間
+
+	fingerprints map[string][32]byte // ファイルごとの内容のハッシュ
+	changedAt    time.Time           // 最後に変更を検出した時刻
+	pending      bool                // 再生成待ちの変更あり
+}
+
+// New creates a Watcher with the system clock and the default timings.
+func New(configPath, outputDir string, out io.Writer) *Watcher {
+	return &Watcher{
+		ConfigPath:   configPath,
+		OutputDir:    outputDir,
+		Out:          out,
+		Options:      func() (generator.Options, error) { return generator.Options{}, nil },
+		Clock:        realClock{},
+		Interval:     DefaultInterval,
+		Debounce:     DefaultDebounce,
+		fingerprints: make(map[string][32]byte),
+	}
+}
+
+// Run builds once and then polls until ctx is canceled.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.snapshot()
+	w.Rebuild()
+	fmt.Fprintf(w.Out, "Watching %s (press Ctrl+C to stop)\n", w.ConfigPath)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.Clock.After(w.Interval):
+			w.Poll()
+		}
+	}
+}
+
+// Poll checks the watched files and rebuilds once they have stopped changing
+// for the debounce period. It reports whether a rebuild ran.
+func (w *Watcher) Poll() bool {
+	if w.snapshot() {
+		w.changedAt = w.Clock.Now()
+		w.pending = true
+		return false
+	}
+	if !w.pending || w.Clock.Now().Sub(w.changedAt) < w.Debounce {
+		return false
+	}
+	w.pending = false
+	w.Rebuild()
+	return true
+}
+
+// Rebuild loads, validates, and generates the configuration and rewrites the
+// scripts whose content changed. Errors are reported instead of returned so
+// that watching continues.
+func (w *Watcher) Rebuild() {
+	written, err := w.build()
+	stamp := w.Clock.Now().Format("15:04:05")
+	switch {
+	case err != nil:
+		fmt.Fprintf(w.Out, "[%s] %v\n", stamp, err)
+	case len(written) == 0:
+		fmt.Fprintf(w.Out, "[%s] No changes\n", stamp)
+	default:
+		fmt.Fprintf(w.Out, "[%s] Updated TTL files:\n", stamp)
+		for _, file := range written {
+			fmt.Fprintf(w.Out, "  - %s\n", file)
+		}
+	}
+}
+
+func (w *Watcher) build() ([]string, error) {
+	// 1. 設定読み込み
+	cfg, err := config.LoadConfig(w.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// 2. バリデーション
+	output.Override(cfg, w.Output)
+	if err := config.Validate(cfg); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	// 3. TTL生成
+	opts, err := w.Options()
+	if err != nil {
+		return nil, err
+	}
+	ttls, err := generator.GenerateAllWithOptions(cfg, filepath.Base(w.ConfigPath), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TTL: %w", err)
+	}
+
+	// 4. 内容が変わったルートのみ書き込み
+	if err := os.MkdirAll(w.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	var written []string
+	for routeName, ttl := range ttls {
+		data, err := output.Encode(ttl, cfg.Options.Output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode route '%s': %w", routeName, err)
+		}
+		filename := filepath.Join(w.OutputDir, routeName+".ttl")
+		if existing, err := os.ReadFile(filename); err == nil && sameScript(existing, data, opts) {
+			continue
+		}
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write TTL file '%s': %w", filename, err)
+		}
+		written = append(written, filename)
+	}
+	sort.Strings(written)
+	return written, nil
+}
+
+// sameScript reports whether the existing script needs no rewrite.
+// Scripts stamped with the current time are compared without their header
+// timestamps; otherwise the header (e.g. the source hash) must match too.
+func sameScript(existing, data []byte, opts generator.Options) bool {
+	if opts.Timestamp.IsZero() {
+		return bytes.Equal(existing, data)
+	}
+	// 文字コード・改行コードの変更も検出するため、エンコード後の内容を比較
+	return differ.Normalize(string(existing)) == differ.Normalize(string(data))
+}
+
+// files returns the files whose changes trigger a rebuild.
+// The configuration has no include mechanism, so this is the config file itself.
+func (w *Watcher) files() []string {
+	return []string{w.ConfigPath}
+}
+
+// snapshot records the content hash of the watched files and reports whether any changed.
+// A missing file counts as empty content so that deleting and recreating it is detected.
+func (w *Watcher) snapshot() bool {
+	changed := false
+	for _, file := range w.files() {
+		data, _ := os.ReadFile(file)
+		sum := sha256.Sum256(data)
+		if prev, ok := w.fingerprints[file]; !ok || prev != sum {
+			changed = ok || changed
+			w.fingerprints[file] = sum
+		}
+	}
+	return changed
+}
@@ -0,0 +1,207 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/JHashimoto0518/ttlx/internal/generator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is advanced manually by the tests.
+type fakeClock struct {
+	now   time.Time
+	ticks chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), ticks: make(chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time                       { return c.now }
+func (c *fakeClock) After(time.Duration) <-chan time.Time { return c.ticks }
+func (c *fakeClock) Advance(d time.Duration)              { c.now = c.now.Add(d) }
+
+const baseConfig = `version: "1.0"
+profiles:
+  bastion:
+    host: bastion.example.com
+    user: user1
+    prompt_marker: "$ "
+    auth:
+      type: password
+  target:
+    host: 10.0.0.50
+    user: user2
+    prompt_marker: "$ "
+    auth:
+      type: password
+      password_prompt: "password:"
+routes:
+  first:
+    - profile: bastion
+  second:
+    - profile: bastion
+    - profile: target
+`
+
+func setup(t *testing.T) (*Watcher, *fakeClock, *bytes.Buffer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte(baseConfig), 0644))
+
+	clock := newFakeClock()
+	var out bytes.Buffer
+	w := New(configPath, filepath.Join(dir, "out"), &out)
+	w.Clock = clock
+	w.Debounce = time.Second
+	w.Options = func() (generator.Options, error) {
+		// 再生成のたびに生成日時が変わる
+		return generator.Options{Timestamp: clock.Now()}, nil
+	}
+	return w, clock, &out, dir
+}
+
+func writeConfig(t *testing.T, dir, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yml"), []byte(content), 0644))
+}
+
+func TestWatcher_RebuildsAfterDebounce(t *testing.T) {
+	w, clock, out, dir := setup(t)
+	w.snapshot()
+	w.Rebuild()
+	assert.Contains(t, out.String(), "out/first.ttl")
+	assert.Contains(t, out.String(), "out/second.ttl")
+	out.Reset()
+
+	// 変更なし
+	clock.Advance(5 * time.Second)
+	assert.False(t, w.Poll())
+
+	// 保存が続く間は再生成しない
+	writeConfig(t, dir, strings.Replace(baseConfig, "user: user2", "user: admin", 1))
+	assert.False(t, w.Poll())
+	clock.Advance(500 * time.Millisecond)
+	writeConfig(t, dir, strings.Replace(baseConfig, "user: user2", "user: root", 1))
+	assert.False(t, w.Poll())
+	clock.Advance(500 * time.Millisecond)
+	assert.False(t, w.Poll())
+
+	// 最後の変更から Debounce 経過後に1回だけ再生成
+	clock.Advance(500 * time.Millisecond)
+	assert.True(t, w.Poll())
+	assert.False(t, w.Poll())
+
+	// 変更されたルートのみ書き込み（生成日時のみの違いは無視）
+	assert.Equal(t, "[09:00:06] Updated TTL files:\n  - "+filepath.Join(dir, "out", "second.ttl")+"\n", out.String())
+	data, err := os.ReadFile(filepath.Join(dir, "out", "second.ttl"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "root@10.0.0.50")
+}
+
+func TestWatcher_ValidationErrorKeepsWatching(t *testing.T) {
+	w, clock, out, dir := setup(t)
+	w.snapshot()
+	w.Rebuild()
+	before, err := os.ReadFile(filepath.Join(dir, "out", "second.ttl"))
+	require.NoError(t, err)
+	out.Reset()
+
+	writeConfig(t, dir, strings.Replace(baseConfig, `      password_prompt: "password:"`+"\n", "", 1))
+	w.Poll()
+	clock.Advance(time.Second)
+	assert.True(t, w.Poll())
+	assert.Contains(t, out.String(), "validation failed: route 'second': profile 'target': password_prompt is required")
+
+	// 既存のファイルはそのまま
+	after, err := os.ReadFile(filepath.Join(dir, "out", "second.ttl"))
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	// 修正すると再生成
+	out.Reset()
+	writeConfig(t, dir, strings.Replace(baseConfig, "user: user2", "user: admin", 1))
+	w.Poll()
+	clock.Advance(time.Second)
+	assert.True(t, w.Poll())
+	assert.Contains(t, out.String(), "Updated TTL files:")
+}
+
+func TestWatcher_NoChanges(t *testing.T) {
+	w, clock, out, dir := setup(t)
+	w.snapshot()
+	w.Rebuild()
+	out.Reset()
+
+	// コメントのみの変更では TTL は変わらない
+	writeConfig(t, dir, "# comment\n"+baseConfig)
+	w.Poll()
+	clock.Advance(time.Second)
+	assert.True(t, w.Poll())
+	assert.Equal(t, "[09:00:01] No changes\n", out.String())
+}
+
+func TestWatcher_SourceHashRewritten(t *testing.T) {
+	w, clock, out, dir := setup(t)
+	configPath := filepath.Join(dir, "config.yml")
+	w.Options = func() (generator.Options, error) {
+		data, err := os.ReadFile(configPath)
+		return generator.Options{SourceHash: generator.SourceHash(data)}, err
+	}
+	w.snapshot()
+	w.Rebuild()
+	out.Reset()
+
+	// ハッシュが変わるため、コメントのみの変更でも書き直す
+	writeConfig(t, dir, "# comment\n"+baseConfig)
+	w.Poll()
+	clock.Advance(time.Second)
+	assert.True(t, w.Poll())
+	assert.Contains(t, out.String(), "first.ttl")
+	assert.Contains(t, out.String(), "second.ttl")
+}
+
+func TestWatcher_Run(t *testing.T) {
+	w, clock, out, dir := setup(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	// 初回ビルド後のポーリング
+	clock.ticks <- clock.now
+	_, err := os.Stat(filepath.Join(dir, "out", "first.ttl"))
+	require.NoError(t, err)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Contains(t, out.String(), "Watching "+filepath.Join(dir, "config.yml"))
+}
+
+func TestWatcher_OutputFormat(t *testing.T) {
+	w, _, out, dir := setup(t)
+	w.Output = config.Output{EOL: config.EOLCRLF}
+	w.snapshot()
+	w.Rebuild()
+
+	data, err := os.ReadFile(filepath.Join(dir, "out", "first.ttl"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "; Route: first\r\n")
+
+	// 改行コードのみの変更でも書き直す
+	out.Reset()
+	w.Output = config.Output{EOL: config.EOLLF}
+	w.Rebuild()
+	assert.Contains(t, out.String(), "first.ttl")
+	data, err = os.ReadFile(filepath.Join(dir, "out", "first.ttl"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "\r\n")
+}
@@ -0,0 +1,113 @@
+package wizard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Build renders the answers as a commented YAML configuration and checks
+// that it passes config.Validate.
+func Build(a *Answers) (string, error) {
+	if len(a.Hops) == 0 {
+		return "", fmt.Errorf("at least one hop is required")
+	}
+	for i, hop := range a.Hops {
+		for _, prev := range a.Hops[:i] {
+			if prev.Name == hop.Name {
+				return "", fmt.Errorf("profile '%s' is used by more than one hop", hop.Name)
+			}
+		}
+	}
+
+	out := Render(a)
+
+	cfg, err := config.ParseConfig([]byte(out))
+	if err != nil {
+		return "", err
+	}
+	if err := config.Validate(cfg); err != nil {
+		return "", fmt.Errorf("validation failed: %w", err)
+	}
+	return out, nil
+}
+
+// Render renders the answers as a commented YAML configuration.
+func Render(a *Answers) string {
+	var sb strings.Builder
+	w := func(format string, args ...interface{}) {
+		fmt.Fprintf(&sb, format, args...)
+		sb.WriteString("\n")
+	}
+
+	w("# ttlx configuration created by ttlx init")
+	w("#   Check:    ttlx validate <this file>")
+	w("#   Generate: ttlx build <this file>")
+	w(`version: "1.0"`)
+	w("")
+	w("# Connection targets. The profile name is also the key in the Tera Term password file.")
+	w("profiles:")
+	for i, hop := range a.Hops {
+		if i > 0 {
+			w("")
+		}
+		w("  %s:", yamlString(hop.Name))
+		w("    host: %s", yamlString(hop.Host))
+		if hop.Port != 0 && hop.Port != defaultPort {
+			w("    port: %d", hop.Port)
+		}
+		w("    user: %s", yamlString(hop.User))
+		w("    prompt_marker: %s  # text at the end of the shell prompt, used to wait for command completion", strconv.Quote(hop.PromptMarker))
+		w("    auth:")
+		switch hop.AuthType {
+		case "keyfile":
+			w("      type: keyfile")
+			if i == 0 {
+				w("      path: %s  # private key used by Tera Term", yamlString(hop.KeyPath))
+			} else {
+				w("      path: %s", yamlString(hop.KeyPath))
+			}
+		default:
+			w("      type: password")
+			w("      password_file: %s  # read with getpassword; created on the first run", yamlString(hop.PasswordFile))
+			if hop.PasswordPrompt != "" {
+				w("      password_prompt: %s  # required from the second hop: text shown when ssh asks for the password", strconv.Quote(hop.PasswordPrompt))
+			}
+		}
+		if hop.IdentityFile != "" {
+			w("    ssh_options:")
+			w("      identity_file: %s  # private key on the previous host, passed to ssh -i", yamlString(hop.IdentityFile))
+		}
+	}
+	w("")
+	w("# Routes list the profiles in connection order.")
+	w("# The first step is connected by Tera Term, later steps by ssh on the previous host.")
+	w("routes:")
+	w("  %s:", yamlString(a.RouteName))
+	for _, hop := range a.Hops {
+		w("    - profile: %s", yamlString(hop.Name))
+	}
+	w("      # commands:  # run on the last host after connecting")
+	w("      #   - hostname")
+	w("")
+	w("# options:")
+	w("#   timeout: 30  # seconds to wait for each prompt")
+	return sb.String()
+}
+
+// yamlString returns s as a YAML scalar, quoting it only when needed.
+func yamlString(s string) string {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	scalar := strings.TrimSuffix(string(out), "\n")
+	if strings.ContainsAny(scalar, "\n'\"") {
+		// 引用符付きの場合はダブルクォートに統一
+		return strconv.Quote(s)
+	}
+	return scalar
+}
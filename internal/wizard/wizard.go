@@ -0,0 +1,289 @@
+// Package wizard builds a starter configuration file from answers to interactive questions or command-line flags.
+package wizard
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+)
+
+// Defaults used when a question is left blank.
+const (
+	DefaultRouteName      = "main"
+	DefaultPromptMarker   = "$ "
+	DefaultPasswordFile   = "passwords.dat"
+	DefaultPasswordPrompt = "password:"
+	DefaultKeyPath        = "~/.ssh/id_rsa"
+	defaultPort           = 22
+)
+
+// Hop is a single connection step of the generated route.
+type Hop struct {
+	Name           string
+	Host           string
+	Port           int
+	User           string
+	PromptMarker   string
+	AuthType       string // "password" | "keyfile"
+	PasswordFile   string
+	PasswordPrompt string // 2段目以降のパスワード認証のみ
+	KeyPath        string
+	IdentityFile   string // 2段目以降の鍵認証のみ（前段のホスト上の秘密鍵）
+}
+
+// Answers holds everything needed to write the configuration file.
+type Answers struct {
+	RouteName string
+	Hops      []Hop
+}
+
+// errEndOfInput is returned when the input ends before all questions are answered.
+var errEndOfInput = errors.New("unexpected end of input")
+
+// prompter asks questions on out and reads the answers line by line from in.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// Ask asks for the route name and each hop in connection order.
+func Ask(in io.Reader, out io.Writer) (*Answers, error) {
+	p := &prompter{in: bufio.NewReader(in), out: out}
+
+	fmt.Fprintln(out, "This wizard creates a ttlx configuration file.")
+	fmt.Fprintln(out, "Enter the hosts in the order they are connected, starting with the host Tera Term connects to.")
+	fmt.Fprintln(out)
+
+	routeName, err := p.ask("Route name", DefaultRouteName, validateRouteName)
+	if err != nil {
+		return nil, err
+	}
+	answers := &Answers{RouteName: routeName}
+
+	for {
+		hop, err := p.askHop(len(answers.Hops)+1, answers.Hops)
+		if err != nil {
+			return nil, err
+		}
+		answers.Hops = append(answers.Hops, hop)
+
+		more, err := p.ask("Add another hop? (y/N)", "n", validateYesNo)
+		if err != nil {
+			return nil, err
+		}
+		if !isYes(more) {
+			return answers, nil
+		}
+	}
+}
+
+func (p *prompter) askHop(stepNum int, previous []Hop) (Hop, error) {
+	fmt.Fprintf(p.out, "\n--- Hop %d ---\n", stepNum)
+
+	var hop Hop
+	var err error
+	if hop.Host, err = p.ask("Host", "", validateRequired); err != nil {
+		return hop, err
+	}
+	port, err := p.ask("Port", strconv.Itoa(defaultPort), validatePort)
+	if err != nil {
+		return hop, err
+	}
+	hop.Port, _ = strconv.Atoi(port)
+	if hop.User, err = p.ask("User", "", validateRequired); err != nil {
+		return hop, err
+	}
+	if hop.Name, err = p.ask("Profile name", defaultProfileName(hop.Host, stepNum), uniqueName(previous)); err != nil {
+		return hop, err
+	}
+	if hop.PromptMarker, err = p.askRaw("Prompt marker (text at the end of the shell prompt)", DefaultPromptMarker); err != nil {
+		return hop, err
+	}
+	if hop.AuthType, err = p.ask("Auth type (password/keyfile)", "password", validateAuthType); err != nil {
+		return hop, err
+	}
+
+	if hop.AuthType == "password" {
+		if hop.PasswordFile, err = p.ask("Password file", DefaultPasswordFile, validateRequired); err != nil {
+			return hop, err
+		}
+		// 2段目以降は ssh のパスワード入力待ちに使用
+		if stepNum > 1 {
+			if hop.PasswordPrompt, err = p.askRaw("Password prompt (text shown when ssh asks for the password)", DefaultPasswordPrompt); err != nil {
+				return hop, err
+			}
+		}
+		return hop, nil
+	}
+
+	if hop.KeyPath, err = p.ask("Private key path", DefaultKeyPath, validateRequired); err != nil {
+		return hop, err
+	}
+	// 2段目以降は前段のホスト上で ssh を実行するため、そのホスト上の秘密鍵を指定
+	if stepNum > 1 {
+		if hop.IdentityFile, err = p.ask("Private key path on the previous host", hop.KeyPath, validateRequired); err != nil {
+			return hop, err
+		}
+	}
+	return hop, nil
+}
+
+// ask asks a question until the trimmed answer passes validate.
+// A blank answer selects def.
+func (p *prompter) ask(question, def string, validate func(string) error) (string, error) {
+	for {
+		answer, err := p.read(question, def)
+		if err != nil {
+			return "", err
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			answer = def
+		}
+		if err := validate(answer); err != nil {
+			fmt.Fprintf(p.out, "  %v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// askRaw asks a question whose answer keeps its spaces, such as a prompt marker.
+func (p *prompter) askRaw(question, def string) (string, error) {
+	answer, err := p.read(question, def)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(answer) == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+func (p *prompter) read(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", errEndOfInput
+		}
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ParseHop parses a hop given as [name=]user@host[:port] for the non-interactive mode.
+// The profile name defaults to the first label of the host name.
+func ParseHop(spec string, stepNum int) (Hop, error) {
+	hop := Hop{Port: defaultPort}
+
+	rest := spec
+	if i := strings.Index(rest, "="); i >= 0 {
+		hop.Name, rest = rest[:i], rest[i+1:]
+	}
+	at := strings.LastIndex(rest, "@")
+	if at <= 0 || at == len(rest)-1 {
+		return hop, fmt.Errorf("invalid hop: %s (must be [name=]user@host[:port])", spec)
+	}
+	hop.User, hop.Host = rest[:at], rest[at+1:]
+
+	if host, port, err := net.SplitHostPort(hop.Host); err == nil {
+		if err := validatePort(port); err != nil {
+			return hop, fmt.Errorf("invalid hop: %s: %w", spec, err)
+		}
+		hop.Host = host
+		hop.Port, _ = strconv.Atoi(port)
+	}
+
+	if hop.Name == "" {
+		hop.Name = defaultProfileName(hop.Host, stepNum)
+	}
+	if err := validateName(hop.Name); err != nil {
+		return hop, fmt.Errorf("invalid hop: %s: %w", spec, err)
+	}
+	return hop, nil
+}
+
+// defaultProfileName derives a profile name from the first label of a host name.
+func defaultProfileName(host string, stepNum int) string {
+	if net.ParseIP(host) == nil {
+		if name, _, _ := strings.Cut(host, "."); config.IsValidProfileName(name) {
+			return name
+		}
+	}
+	return fmt.Sprintf("hop%d", stepNum)
+}
+
+func validateRequired(s string) error {
+	if s == "" {
+		return errors.New("a value is required")
+	}
+	return nil
+}
+
+func validateRouteName(s string) error {
+	if !config.IsValidFileName(s) {
+		return fmt.Errorf("invalid name: %s (use only alphanumeric, hyphens, and underscores)", s)
+	}
+	return nil
+}
+
+func validateName(s string) error {
+	if !config.IsValidProfileName(s) {
+		return fmt.Errorf("invalid name: %s (use only alphanumeric, hyphens, underscores, and dots)", s)
+	}
+	return nil
+}
+
+func uniqueName(previous []Hop) func(string) error {
+	return func(s string) error {
+		if err := validateName(s); err != nil {
+			return err
+		}
+		for _, hop := range previous {
+			if hop.Name == s {
+				return fmt.Errorf("profile '%s' is already used by another hop", s)
+			}
+		}
+		return nil
+	}
+}
+
+func validatePort(s string) error {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port: %s (must be 1-65535)", s)
+	}
+	return nil
+}
+
+func validateAuthType(s string) error {
+	if s != "password" && s != "keyfile" {
+		return fmt.Errorf("invalid auth type: %s (must be 'password' or 'keyfile')", s)
+	}
+	return nil
+}
+
+func validateYesNo(s string) error {
+	switch strings.ToLower(s) {
+	case "y", "yes", "n", "no":
+		return nil
+	}
+	return fmt.Errorf("invalid answer: %s (must be 'y' or 'n')", s)
+}
+
+func isYes(s string) bool {
+	s = strings.ToLower(s)
+	return s == "y" || s == "yes"
+}
@@ -0,0 +1,204 @@
+package wizard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// script joins the answers typed by the user, one per line.
+func script(lines ...string) *strings.Reader {
+	return strings.NewReader(strings.Join(lines, "\n") + "\n")
+}
+
+func TestAsk_ScriptedInput(t *testing.T) {
+	in := script(
+		"",                    // Route name (main)
+		"bastion.example.com", // Host
+		"",                    // Port (22)
+		"user1",               // User
+		"",                    // Profile name (bastion)
+		"",                    // Prompt marker ($ )
+		"",                    // Auth type (password)
+		"",                    // Password file (passwords.dat)
+		"y",                   // Add another hop
+		"10.0.0.50",           // Host
+		"2222",                // Port
+		"user2",               // User
+		"web-01",              // Profile name
+		"# ",                  // Prompt marker
+		"password",            // Auth type
+		"secrets.dat",         // Password file
+		"",                    // Password prompt (password:)
+		"n",                   // Add another hop
+	)
+	var out bytes.Buffer
+
+	answers, err := Ask(in, &out)
+	require.NoError(t, err)
+
+	assert.Equal(t, &Answers{
+		RouteName: "main",
+		Hops: []Hop{
+			{Name: "bastion", Host: "bastion.example.com", Port: 22, User: "user1", PromptMarker: "$ ", AuthType: "password", PasswordFile: "passwords.dat"},
+			{Name: "web-01", Host: "10.0.0.50", Port: 2222, User: "user2", PromptMarker: "# ", AuthType: "password", PasswordFile: "secrets.dat", PasswordPrompt: "password:"},
+		},
+	}, answers)
+	assert.Contains(t, out.String(), "--- Hop 2 ---")
+
+	// 生成したYAMLはバリデーションを通過
+	yml, err := Build(answers)
+	require.NoError(t, err)
+	cfg, err := config.ParseConfig([]byte(yml))
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(cfg))
+
+	assert.Equal(t, "$ ", cfg.Profiles["bastion"].PromptMarker)
+	assert.Empty(t, cfg.Profiles["bastion"].Auth.PasswordPrompt)
+	assert.Equal(t, 2222, cfg.Profiles["web-01"].Port)
+	assert.Equal(t, "password:", cfg.Profiles["web-01"].Auth.PasswordPrompt)
+	assert.Equal(t, []string{"bastion", "web-01"}, []string{cfg.Routes["main"].Steps[0].Profile, cfg.Routes["main"].Steps[1].Profile})
+	assert.Contains(t, yml, "# ttlx configuration created by ttlx init\n")
+}
+
+func TestAsk_Keyfile(t *testing.T) {
+	in := script(
+		"jump", "bastion", "", "ops", "", "", "keyfile", "~/.ssh/id_ed25519", "y",
+		"db.internal", "", "ops", "", "", "keyfile", "", "/home/ops/.ssh/id_db", "n",
+	)
+
+	answers, err := Ask(in, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Equal(t, "~/.ssh/id_ed25519", answers.Hops[0].KeyPath)
+	assert.Empty(t, answers.Hops[0].IdentityFile)
+	assert.Equal(t, "~/.ssh/id_rsa", answers.Hops[1].KeyPath)
+	assert.Equal(t, "/home/ops/.ssh/id_db", answers.Hops[1].IdentityFile)
+
+	yml, err := Build(answers)
+	require.NoError(t, err)
+	cfg, err := config.ParseConfig([]byte(yml))
+	require.NoError(t, err)
+	assert.Equal(t, "/home/ops/.ssh/id_db", cfg.Profiles["db"].SSHOptions.IdentityFile)
+	assert.Contains(t, cfg.Routes, "jump")
+}
+
+func TestAsk_InvalidAnswersAreAskedAgain(t *testing.T) {
+	in := script(
+		"my route",            // Route name: invalid
+		"main",                // Route name
+		"",                    // Host: required
+		"bastion.example.com", // Host
+		"70000",               // Port: out of range
+		"22",                  // Port
+		"user1",               // User
+		"bastion",             // Profile name
+		"",                    // Prompt marker
+		"telnet",              // Auth type: invalid
+		"password",            // Auth type
+		"",                    // Password file
+		"maybe",               // Add another hop: invalid
+		"yes",                 // Add another hop
+		"bastion.example.com", // Host
+		"",                    // Port
+		"user2",               // User
+		"bastion",             // Profile name: already used
+		"bastion2",            // Profile name
+		"",                    // Prompt marker
+		"",                    // Auth type
+		"",                    // Password file
+		"",                    // Password prompt
+		"",                    // Add another hop (n)
+	)
+	var out bytes.Buffer
+
+	answers, err := Ask(in, &out)
+	require.NoError(t, err)
+	assert.Equal(t, "main", answers.RouteName)
+	require.Len(t, answers.Hops, 2)
+	assert.Equal(t, "bastion2", answers.Hops[1].Name)
+
+	for _, msg := range []string{
+		"invalid name: my route",
+		"a value is required",
+		"invalid port: 70000",
+		"invalid auth type: telnet",
+		"invalid answer: maybe",
+		"profile 'bastion' is already used by another hop",
+	} {
+		assert.Contains(t, out.String(), msg)
+	}
+}
+
+func TestAsk_EndOfInput(t *testing.T) {
+	_, err := Ask(script("main", "bastion.example.com"), &bytes.Buffer{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected end of input")
+}
+
+func TestParseHop(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		stepNum  int
+		expected Hop
+		errMsg   string
+	}{
+		{name: "name from host", spec: "user1@bastion.example.com", stepNum: 1, expected: Hop{Name: "bastion", Host: "bastion.example.com", Port: 22, User: "user1"}},
+		{name: "explicit name and port", spec: "web=user2@10.0.0.50:2222", stepNum: 2, expected: Hop{Name: "web", Host: "10.0.0.50", Port: 2222, User: "user2"}},
+		{name: "ip address", spec: "user2@10.0.0.50", stepNum: 2, expected: Hop{Name: "hop2", Host: "10.0.0.50", Port: 22, User: "user2"}},
+		{name: "user with at sign", spec: "user@example.com@host", stepNum: 1, expected: Hop{Name: "host", Host: "host", Port: 22, User: "user@example.com"}},
+		{name: "missing user", spec: "bastion.example.com", stepNum: 1, errMsg: "invalid hop: bastion.example.com"},
+		{name: "invalid port", spec: "user@host:0", stepNum: 1, errMsg: "invalid port: 0"},
+		{name: "invalid name", spec: "web server=user@host", stepNum: 1, errMsg: "invalid name: web server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hop, err := ParseHop(tt.spec, tt.stepNum)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, hop)
+		})
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	hop := Hop{Name: "bastion", Host: "bastion.example.com", Port: 22, User: "user1", PromptMarker: "$ ", AuthType: "password", PasswordFile: "passwords.dat"}
+
+	_, err := Build(&Answers{RouteName: "main"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at least one hop is required")
+
+	_, err = Build(&Answers{RouteName: "main", Hops: []Hop{hop, hop}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile 'bastion' is used by more than one hop")
+
+	// 2段目以降のパスワード認証に password_prompt がない
+	second := hop
+	second.Name = "target"
+	_, err = Build(&Answers{RouteName: "main", Hops: []Hop{hop, second}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "password_prompt is required")
+}
+
+func TestYamlString(t *testing.T) {
+	tests := map[string]string{
+		"bastion.example.com": "bastion.example.com",
+		"~/.ssh/id_rsa":       "~/.ssh/id_rsa",
+		"22":                  `"22"`,
+		"yes":                 `"yes"`,
+		"a: b":                `"a: b"`,
+		"it's":                `"it's"`,
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, yamlString(input), input)
+	}
+}
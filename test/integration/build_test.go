@@ -2,10 +2,13 @@ package integration
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/JHashimoto0518/ttlx/internal/config"
+	"github.com/JHashimoto0518/ttlx/internal/differ"
 	"github.com/JHashimoto0518/ttlx/internal/generator"
+	"github.com/JHashimoto0518/ttlx/internal/output"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -71,7 +74,7 @@ func TestBuild_FullConfig(t *testing.T) {
 	assert.Contains(t, ttl, "connect connectcmd")
 	assert.Contains(t, ttl, "sendln 'su - root'")
 	assert.Contains(t, ttl, "sendln 'cd /var/log'")
-	assert.Contains(t, ttl, "ssh user2@10.0.0.50")
+	assert.Contains(t, ttl, "ssh -i \"~/.ssh/id_rsa\" user2@10.0.0.50")
 	assert.Contains(t, ttl, "sendln 'ps aux'")
 	assert.Contains(t, ttl, "sendln 'df -h'")
 }
@@ -156,3 +159,96 @@ func TestBuild_EndToEnd(t *testing.T) {
 	require.NoError(t, err)
 	assert.Contains(t, string(content), "Generated by ttlx")
 }
+
+func TestBuild_Reproducible(t *testing.T) {
+	configPath := "../fixtures/valid/full.yml"
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+
+	cfg, err := config.LoadConfig(configPath)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(cfg))
+
+	// タイムスタンプの代わりに設定ファイルのハッシュを埋め込み、何度生成しても同じ内容になる
+	opts := generator.Options{SourceHash: generator.SourceHash(data)}
+	first, err := generator.GenerateAllWithOptions(cfg, "full.yml", opts)
+	require.NoError(t, err)
+	second, err := generator.GenerateAllWithOptions(cfg, "full.yml", opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	for _, ttl := range first {
+		assert.NotContains(t, ttl, "Generated at")
+		assert.Contains(t, ttl, "; Source SHA-256: "+generator.SourceHash(data)+"\n")
+	}
+}
+
+func TestDiff_AgainstBuiltFiles(t *testing.T) {
+	cfg, err := config.LoadConfig("../fixtures/valid/multiple-routes.yml")
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(cfg))
+
+	// 既存ファイルはタイムスタンプ付きで生成されている
+	built, err := generator.GenerateAll(cfg, "multiple-routes.yml")
+	require.NoError(t, err)
+	current, err := generator.GenerateAllWithOptions(cfg, "multiple-routes.yml", generator.Options{})
+	require.NoError(t, err)
+
+	results, err := differ.Compare(built, current, "out", "multiple-routes.yml")
+	require.NoError(t, err)
+	assert.False(t, differ.HasChanges(results))
+
+	// ルートを1つ削除すると removed として検出
+	for name := range cfg.Routes {
+		delete(cfg.Routes, name)
+		break
+	}
+	current, err = generator.GenerateAllWithOptions(cfg, "multiple-routes.yml", generator.Options{})
+	require.NoError(t, err)
+	results, err = differ.Compare(built, current, "out", "multiple-routes.yml")
+	require.NoError(t, err)
+
+	var removed int
+	for _, r := range results {
+		if r.Status == differ.Removed {
+			removed++
+		}
+	}
+	assert.Equal(t, 1, removed)
+}
+
+func TestBuild_OutputEncoding(t *testing.T) {
+	cfg, err := config.LoadConfig("../fixtures/valid/output-sjis.yml")
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(cfg))
+
+	ttls, err := generator.GenerateAll(cfg, "output-sjis.yml")
+	require.NoError(t, err)
+	data, err := output.Encode(ttls["japanese"], cfg.Options.Output)
+	require.NoError(t, err)
+
+	// Shift_JIS・CRLF で出力され、読み戻すと生成結果と一致
+	assert.Contains(t, string(data), "sendln 'echo \"\x90\xda\x91\xb1\x82\xb5\x82\xdc\x82\xb5\x82\xbd\"'\r\n")
+	assert.NotContains(t, strings.ReplaceAll(string(data), "\r\n", ""), "\n")
+	decoded, err := output.Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, ttls["japanese"], decoded)
+}
+
+func TestBuild_RouteSelection(t *testing.T) {
+	// 1. YAML読み込み
+	cfg, err := config.LoadConfig("../fixtures/valid/route-metadata.yml")
+	require.NoError(t, err)
+
+	// 2. ルート選択とバリデーション
+	names, err := config.SelectRoutes(cfg, []string{"prod-*"}, []string{"db"})
+	require.NoError(t, err)
+	config.KeepRoutes(cfg, names)
+	require.NoError(t, config.Validate(cfg))
+
+	// 3. TTL生成（選択したルートのみ）
+	ttls, err := generator.GenerateAllWithOptions(cfg, "route-metadata.yml", generator.Options{})
+	require.NoError(t, err)
+	require.Len(t, ttls, 1)
+	assert.Contains(t, ttls["prod-db"], "; Description: Production database (read-only account).\n")
+}